@@ -0,0 +1,189 @@
+package gmailfilter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/action"
+	"github.com/hashicorp/terraform-plugin-framework/action/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+var _ action.Action = &BatchModifyAction{}
+var _ action.ActionWithConfigure = &BatchModifyAction{}
+var _ action.ActionWithValidateConfig = &BatchModifyAction{}
+
+func NewBatchModifyAction() action.Action {
+	return &BatchModifyAction{}
+}
+
+type BatchModifyAction struct {
+	config *Config
+}
+
+type BatchModifyActionModel struct {
+	Query            types.String `tfsdk:"query"`
+	AddLabelIDs      types.List   `tfsdk:"add_label_ids"`
+	RemoveLabelIDs   types.List   `tfsdk:"remove_label_ids"`
+	AddLabelNames    types.List   `tfsdk:"add_label_names"`
+	RemoveLabelNames types.List   `tfsdk:"remove_label_names"`
+	Limit            types.Int64  `tfsdk:"limit"`
+}
+
+func (a *BatchModifyAction) Metadata(ctx context.Context, req action.MetadataRequest, resp *action.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_batch_modify"
+}
+
+func (a *BatchModifyAction) Schema(ctx context.Context, req action.SchemaRequest, resp *action.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Adds and removes labels on all messages matching a Gmail query, as a one-off operation. " +
+			"For example, to archive everything older than a year in Notifications, use the query \"label:Notifications older_than:1y\" and remove the INBOX label.",
+		Attributes: map[string]schema.Attribute{
+			"query": schema.StringAttribute{
+				Required:    true,
+				Description: "The Gmail search query selecting the messages",
+			},
+			"add_label_ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "IDs of labels to add to the messages",
+			},
+			"remove_label_ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "IDs of labels to remove from the messages",
+			},
+			"add_label_names": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Names of labels to add to the messages, matched ignoring case",
+			},
+			"remove_label_names": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Names of labels to remove from the messages, matched ignoring case",
+			},
+			"limit": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The maximum number of messages to modify. Unlimited when not set.",
+				Validators: []validator.Int64{
+					int64AtLeast(1),
+				},
+			},
+		},
+	}
+}
+
+func (a *BatchModifyAction) Configure(ctx context.Context, req action.ConfigureRequest, resp *action.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Action Configure Type", "Expected *Config")
+		return
+	}
+	a.config = config
+}
+
+func (a *BatchModifyAction) ValidateConfig(ctx context.Context, req action.ValidateConfigRequest, resp *action.ValidateConfigResponse) {
+	var data BatchModifyActionModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if v := data.Query; !v.IsUnknown() && strings.TrimSpace(v.ValueString()) == "" {
+		resp.Diagnostics.AddAttributeError(path.Root("query"), "Invalid query", "query must not be empty; use \"in:anywhere\" to modify every message")
+	}
+	for _, v := range []types.List{data.AddLabelIDs, data.RemoveLabelIDs, data.AddLabelNames, data.RemoveLabelNames} {
+		if v.IsUnknown() || !v.IsNull() && len(v.Elements()) > 0 {
+			return
+		}
+	}
+	resp.Diagnostics.AddError("Invalid batch modify", "At least one of add_label_ids, remove_label_ids, add_label_names and remove_label_names must contain a label")
+}
+
+func (a *BatchModifyAction) Invoke(ctx context.Context, req action.InvokeRequest, resp *action.InvokeResponse) {
+	var data BatchModifyActionModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	add := a.labelIDs(ctx, data.AddLabelIDs, data.AddLabelNames, path.Root("add_label_names"), resp)
+	remove := a.labelIDs(ctx, data.RemoveLabelIDs, data.RemoveLabelNames, path.Root("remove_label_names"), resp)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		resp.Diagnostics.AddError("Invalid batch modify", "No labels to add or remove; add_label_ids, remove_label_ids, add_label_names and remove_label_names are all empty")
+		return
+	}
+
+	query := data.Query.ValueString()
+	ids, err := searchMessageIDs(ctx, a.config.gmailService, query, nil, int(data.Limit.ValueInt64()))
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to search messages", err.Error())
+		return
+	}
+	tflog.Info(ctx, "Modifying messages", map[string]any{
+		"query":    query,
+		"messages": len(ids),
+		"add":      add,
+		"remove":   remove,
+	})
+	resp.SendProgress(action.InvokeProgressEvent{
+		Message: fmt.Sprintf("Found %d messages matching %q", len(ids), query),
+	})
+
+	err = batchModifyMessages(ctx, a.config.gmailService, ids, add, remove, func(done int) {
+		resp.SendProgress(action.InvokeProgressEvent{
+			Message: fmt.Sprintf("Modified %d of %d messages", done, len(ids)),
+		})
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to modify messages", err.Error())
+		return
+	}
+}
+
+// labelIDs returns the label IDs in ids together with the IDs of the labels
+// named in names. Like Gmail, names are matched ignoring case. Unknown names
+// are reported against namesPath.
+func (a *BatchModifyAction) labelIDs(ctx context.Context, ids, names types.List, namesPath path.Path, resp *action.InvokeResponse) []string {
+	var result, labelNames []string
+	if !ids.IsNull() {
+		resp.Diagnostics.Append(ids.ElementsAs(ctx, &result, false)...)
+	}
+	if !names.IsNull() {
+		resp.Diagnostics.Append(names.ElementsAs(ctx, &labelNames, false)...)
+	}
+	if resp.Diagnostics.HasError() || len(labelNames) == 0 {
+		return result
+	}
+
+	res, err := a.config.gmailService.Users.Labels.List(gmailUser).Context(ctx).Do()
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list labels", err.Error())
+		return nil
+	}
+	byName := map[string]string{}
+	for _, label := range res.Labels {
+		byName[strings.ToLower(label.Name)] = label.Id
+	}
+	for i, name := range labelNames {
+		id, ok := byName[strings.ToLower(name)]
+		if !ok {
+			resp.Diagnostics.AddAttributeError(namesPath.AtListIndex(i), "Label not found",
+				fmt.Sprintf("No label with name %q found", name))
+			continue
+		}
+		result = append(result, id)
+	}
+	return result
+}
@@ -2,6 +2,7 @@ package gmailfilter
 
 import (
 	"context"
+	"sync"
 
 	"google.golang.org/api/gmail/v1"
 )
@@ -14,6 +15,9 @@ const (
 // provider.
 type Config struct {
 	gmailService *gmail.Service
+
+	labelIDsMu sync.Mutex
+	labelIDs   map[string]bool
 }
 
 func (c *Config) LoadAndValidate(ctx context.Context) error {
@@ -24,3 +28,33 @@ func (c *Config) LoadAndValidate(ctx context.Context) error {
 	c.gmailService = gmailService
 	return nil
 }
+
+// labelExists reports whether a label with the given ID exists in the
+// mailbox. The labels are listed once and the result, hits and misses alike,
+// is cached, so every resource validating label IDs during a plan shares a
+// single API call. Labels created by this provider reset the cache through
+// forgetLabels.
+func (c *Config) labelExists(id string) (bool, error) {
+	c.labelIDsMu.Lock()
+	defer c.labelIDsMu.Unlock()
+
+	if c.labelIDs == nil {
+		res, err := c.gmailService.Users.Labels.List(gmailUser).Do()
+		if err != nil {
+			return false, err
+		}
+		c.labelIDs = map[string]bool{}
+		for _, label := range res.Labels {
+			c.labelIDs[label.Id] = true
+		}
+	}
+	return c.labelIDs[id], nil
+}
+
+// forgetLabels drops the labels cached by labelExists, so that labels created
+// since are found.
+func (c *Config) forgetLabels() {
+	c.labelIDsMu.Lock()
+	defer c.labelIDsMu.Unlock()
+	c.labelIDs = nil
+}
@@ -2,14 +2,20 @@ package gmailfilter
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
-	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"google.golang.org/api/gmail/v1"
 )
 
 var _ datasource.DataSource = &FilterDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &FilterDataSource{}
 
 func NewFilterDataSource() datasource.DataSource {
 	return &FilterDataSource{}
@@ -31,73 +37,23 @@ func (d *FilterDataSource) Metadata(ctx context.Context, req datasource.Metadata
 
 func (d *FilterDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		Description: "Reads a Gmail filter",
+		Description: "Reads a Gmail filter by ID, or by any combination of criteria fields. Exactly one of id and criteria must be set.",
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
-				Required:    true,
+				Optional:    true,
+				Computed:    true,
 				Description: "The ID of the filter",
 			},
 			"action": schema.SingleNestedAttribute{
 				Computed:    true,
 				Description: "Action that the filter performs",
-				Attributes: map[string]schema.Attribute{
-					"add_label_ids": schema.ListAttribute{
-						ElementType: types.StringType,
-						Computed:    true,
-						Description: "List of labels to add to the message",
-					},
-					"forward": schema.StringAttribute{
-						Computed:    true,
-						Description: "Email address that the message should be forwarded to",
-					},
-					"remove_label_ids": schema.ListAttribute{
-						ElementType: types.StringType,
-						Computed:    true,
-						Description: "List of labels to remove from the message",
-					},
-				},
+				Attributes:  filterActionDataSourceAttributes(false, true),
 			},
 			"criteria": schema.SingleNestedAttribute{
+				Optional:    true,
 				Computed:    true,
-				Description: "The criteria that a message should match to apply the filter",
-				Attributes: map[string]schema.Attribute{
-					"exclude_chats": schema.BoolAttribute{
-						Computed:    true,
-						Description: "Whether the response should exclude chats",
-					},
-					"from": schema.StringAttribute{
-						Computed:    true,
-						Description: "The sender's display name or email address",
-					},
-					"has_attachment": schema.BoolAttribute{
-						Computed:    true,
-						Description: "Whether the message has any attachment",
-					},
-					"negated_query": schema.StringAttribute{
-						Computed:    true,
-						Description: "Only return messages not matching the specified query",
-					},
-					"query": schema.StringAttribute{
-						Computed:    true,
-						Description: "Only return messages matching the specified query",
-					},
-					"size": schema.Int64Attribute{
-						Computed:    true,
-						Description: "The size of the entire RFC822 message in bytes",
-					},
-					"size_comparison": schema.StringAttribute{
-						Computed:    true,
-						Description: "How the message size should be compared",
-					},
-					"subject": schema.StringAttribute{
-						Computed:    true,
-						Description: "Case-insensitive phrase found in the message's subject",
-					},
-					"to": schema.StringAttribute{
-						Computed:    true,
-						Description: "The recipient's display name or email address",
-					},
-				},
+				Description: "The criteria that a message should match to apply the filter. When id is not set, the criteria fields that are set select the filter.",
+				Attributes:  filterCriteriaDataSourceAttributes(true, true),
 			},
 		},
 	}
@@ -122,58 +78,141 @@ func (d *FilterDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
-	filter, err := d.config.gmailService.Users.Settings.Filters.Get(gmailUser, data.ID.ValueString()).Do()
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to read filter", err.Error())
-		return
+	var filter *gmail.Filter
+	if !data.ID.IsNull() {
+		var err error
+		filter, err = d.config.gmailService.Users.Settings.Filters.Get(gmailUser, data.ID.ValueString()).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to read filter", err.Error())
+			return
+		}
+	} else {
+		var criteria FilterCriteriaModel
+		resp.Diagnostics.Append(data.Criteria.As(ctx, &criteria, basetypes.ObjectAsOptions{})...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		var err error
+		filter, err = d.findFilter(criteria)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to find filter", err.Error())
+			return
+		}
 	}
 
-	// Convert action to object
-	actionObject, diags := types.ObjectValueFrom(ctx, map[string]attr.Type{
-		"add_label_ids":    types.ListType{ElemType: types.StringType},
-		"forward":          types.StringType,
-		"remove_label_ids": types.ListType{ElemType: types.StringType},
-	}, FilterActionModel{
-		AddLabelIds:    convertStringSliceToList(ctx, filter.Action.AddLabelIds),
-		Forward:        types.StringValue(filter.Action.Forward),
-		RemoveLabelIds: convertStringSliceToList(ctx, filter.Action.RemoveLabelIds),
-	})
-	resp.Diagnostics.Append(diags...)
-
-	// Convert criteria to object
-	criteriaObject, diags := types.ObjectValueFrom(ctx, map[string]attr.Type{
-		"exclude_chats":   types.BoolType,
-		"from":            types.StringType,
-		"has_attachment":  types.BoolType,
-		"negated_query":   types.StringType,
-		"query":           types.StringType,
-		"size":            types.Int64Type,
-		"size_comparison": types.StringType,
-		"subject":         types.StringType,
-		"to":              types.StringType,
-	}, FilterCriteriaModel{
-		ExcludeChats:   types.BoolValue(filter.Criteria.ExcludeChats),
-		From:           types.StringValue(filter.Criteria.From),
-		HasAttachment:  types.BoolValue(filter.Criteria.HasAttachment),
-		NegatedQuery:   types.StringValue(filter.Criteria.NegatedQuery),
-		Query:          types.StringValue(filter.Criteria.Query),
-		Size:           types.Int64Value(filter.Criteria.Size),
-		SizeComparison: types.StringValue(filter.Criteria.SizeComparison),
-		Subject:        types.StringValue(filter.Criteria.Subject),
-		To:             types.StringValue(filter.Criteria.To),
-	})
+	actionObject, criteriaObject, diags := flattenFilterDataSource(ctx, filter)
 	resp.Diagnostics.Append(diags...)
-
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
+	data.ID = types.StringValue(filter.Id)
 	data.Action = actionObject
 	data.Criteria = criteriaObject
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
+func (d *FilterDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var data FilterDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.ID.IsNull() == data.Criteria.IsNull() {
+		resp.Diagnostics.AddError("Invalid filter lookup", "Exactly one of id and criteria must be set")
+		return
+	}
+	if data.Criteria.IsNull() || data.Criteria.IsUnknown() {
+		return
+	}
+	for _, v := range data.Criteria.Attributes() {
+		if !v.IsNull() {
+			return
+		}
+	}
+	resp.Diagnostics.AddAttributeError(path.Root("criteria"), "Invalid filter lookup", "At least one criteria field must be set")
+}
+
+// findFilter returns the single filter whose criteria match every field that
+// is set in lookup. Strings are compared ignoring case.
+func (d *FilterDataSource) findFilter(lookup FilterCriteriaModel) (*gmail.Filter, error) {
+	filters, err := listFilters(d.config.gmailService)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*gmail.Filter
+	for _, f := range filters {
+		if filterCriteriaMatches(f.Criteria, lookup) {
+			matches = append(matches, f)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no filter matches the given criteria")
+	case 1:
+		return matches[0], nil
+	}
+	candidates := make([]string, len(matches))
+	for i, f := range matches {
+		candidates[i] = fmt.Sprintf("%s (%s)", f.Id, describeFilterCriteria(f.Criteria))
+	}
+	return nil, fmt.Errorf("%d filters match the given criteria, the lookup must match exactly one; set more criteria fields or the id: %s", len(matches), strings.Join(candidates, ", "))
+}
+
+func filterCriteriaMatches(criteria *gmail.FilterCriteria, lookup FilterCriteriaModel) bool {
+	if criteria == nil {
+		criteria = &gmail.FilterCriteria{}
+	}
+	stringMatches := func(v types.String, actual string) bool {
+		return v.IsNull() || strings.EqualFold(v.ValueString(), actual)
+	}
+	return stringMatches(lookup.From, criteria.From) &&
+		stringMatches(lookup.To, criteria.To) &&
+		stringMatches(lookup.Subject, criteria.Subject) &&
+		stringMatches(lookup.Query, criteria.Query) &&
+		stringMatches(lookup.NegatedQuery, criteria.NegatedQuery) &&
+		stringMatches(lookup.SizeComparison, criteria.SizeComparison) &&
+		(lookup.Size.IsNull() || lookup.Size.ValueInt64() == criteria.Size) &&
+		(lookup.HasAttachment.IsNull() || lookup.HasAttachment.ValueBool() == criteria.HasAttachment) &&
+		(lookup.ExcludeChats.IsNull() || lookup.ExcludeChats.ValueBool() == criteria.ExcludeChats)
+}
+
+// describeFilterCriteria summarizes the non-empty criteria of a filter for
+// use in messages, e.g. `from:"a@example.com" subject:"hi"`.
+func describeFilterCriteria(criteria *gmail.FilterCriteria) string {
+	if criteria == nil {
+		return "no criteria"
+	}
+	var parts []string
+	add := func(name, value string) {
+		if value != "" {
+			parts = append(parts, fmt.Sprintf("%s:%q", name, value))
+		}
+	}
+	add("from", criteria.From)
+	add("to", criteria.To)
+	add("subject", criteria.Subject)
+	add("query", criteria.Query)
+	add("negated_query", criteria.NegatedQuery)
+	if criteria.Size != 0 {
+		parts = append(parts, fmt.Sprintf("size:%s %d", criteria.SizeComparison, criteria.Size))
+	}
+	if criteria.HasAttachment {
+		parts = append(parts, "has_attachment")
+	}
+	if criteria.ExcludeChats {
+		parts = append(parts, "exclude_chats")
+	}
+	if len(parts) == 0 {
+		return "no criteria"
+	}
+	return strings.Join(parts, " ")
+}
+
 func convertStringSliceToList(ctx context.Context, slice []string) types.List {
 	if slice == nil {
 		return types.ListNull(types.StringType)
@@ -181,3 +220,118 @@ func convertStringSliceToList(ctx context.Context, slice []string) types.List {
 	list, _ := types.ListValueFrom(ctx, types.StringType, slice)
 	return list
 }
+
+// filterActionDataSourceAttributes returns the action attributes of the filter
+// data sources.
+func filterActionDataSourceAttributes(optional, computed bool) map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"add_label_ids": schema.ListAttribute{
+			ElementType: types.StringType,
+			Optional:    optional,
+			Computed:    computed,
+			Description: "List of labels to add to the message",
+		},
+		"forward": schema.StringAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "Email address that the message should be forwarded to",
+		},
+		"remove_label_ids": schema.ListAttribute{
+			ElementType: types.StringType,
+			Optional:    optional,
+			Computed:    computed,
+			Description: "List of labels to remove from the message",
+		},
+	}
+}
+
+// filterCriteriaDataSourceAttributes returns the criteria attributes of the
+// filter data sources. They are optional where filters are looked up by or
+// given as criteria.
+func filterCriteriaDataSourceAttributes(optional, computed bool) map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"exclude_chats": schema.BoolAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "Whether the response should exclude chats",
+		},
+		"from": schema.StringAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "The sender's display name or email address",
+		},
+		"has_attachment": schema.BoolAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "Whether the message has any attachment",
+		},
+		"negated_query": schema.StringAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "Only return messages not matching the specified query",
+		},
+		"query": schema.StringAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "Only return messages matching the specified query",
+		},
+		"size": schema.Int64Attribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "The size of the entire RFC822 message in bytes",
+		},
+		"size_comparison": schema.StringAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "How the message size should be compared",
+		},
+		"subject": schema.StringAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "Case-insensitive phrase found in the message's subject",
+		},
+		"to": schema.StringAttribute{
+			Optional:    optional,
+			Computed:    computed,
+			Description: "The recipient's display name or email address",
+		},
+	}
+}
+
+// flattenFilterDataSource converts the action and criteria of an API filter
+// into the objects used by the filter data sources.
+func flattenFilterDataSource(ctx context.Context, filter *gmail.Filter) (types.Object, types.Object, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	action := filter.Action
+	if action == nil {
+		action = &gmail.FilterAction{}
+	}
+	criteria := filter.Criteria
+	if criteria == nil {
+		criteria = &gmail.FilterCriteria{}
+	}
+
+	// Convert action to object
+	actionObject, d := types.ObjectValueFrom(ctx, filterActionAttrTypes, FilterActionModel{
+		AddLabelIds:    convertStringSliceToList(ctx, action.AddLabelIds),
+		Forward:        types.StringValue(action.Forward),
+		RemoveLabelIds: convertStringSliceToList(ctx, action.RemoveLabelIds),
+	})
+	diags.Append(d...)
+
+	// Convert criteria to object
+	criteriaObject, d := types.ObjectValueFrom(ctx, filterCriteriaAttrTypes, FilterCriteriaModel{
+		ExcludeChats:   types.BoolValue(criteria.ExcludeChats),
+		From:           types.StringValue(criteria.From),
+		HasAttachment:  types.BoolValue(criteria.HasAttachment),
+		NegatedQuery:   types.StringValue(criteria.NegatedQuery),
+		Query:          types.StringValue(criteria.Query),
+		Size:           types.Int64Value(criteria.Size),
+		SizeComparison: types.StringValue(criteria.SizeComparison),
+		Subject:        types.StringValue(criteria.Subject),
+		To:             types.StringValue(criteria.To),
+	})
+	diags.Append(d...)
+
+	return actionObject, criteriaObject, diags
+}
@@ -0,0 +1,370 @@
+package gmailfilter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/gmail/v1"
+)
+
+var _ datasource.DataSource = &FilterAnalysisDataSource{}
+
+func NewFilterAnalysisDataSource() datasource.DataSource {
+	return &FilterAnalysisDataSource{}
+}
+
+type FilterAnalysisDataSource struct {
+	config *Config
+}
+
+type FilterAnalysisDataSourceModel struct {
+	FilterIDs      types.Set   `tfsdk:"filter_ids"`
+	Filters        types.List  `tfsdk:"filters"`
+	Conflicts      types.List  `tfsdk:"conflicts"`
+	Duplicates     types.List  `tfsdk:"duplicates"`
+	Subsumed       types.List  `tfsdk:"subsumed"`
+	SharedForwards types.List  `tfsdk:"shared_forwards"`
+	IssueCount     types.Int64 `tfsdk:"issue_count"`
+}
+
+type FilterConflictModel struct {
+	AddingFilterID   string `tfsdk:"adding_filter_id"`
+	RemovingFilterID string `tfsdk:"removing_filter_id"`
+	LabelID          string `tfsdk:"label_id"`
+	Overlap          string `tfsdk:"overlap"`
+}
+
+type FilterDuplicateModel struct {
+	FilterIDs []string `tfsdk:"filter_ids"`
+}
+
+type FilterSubsumedModel struct {
+	FilterID    string `tfsdk:"filter_id"`
+	SubsumedBy  string `tfsdk:"subsumed_by"`
+	Description string `tfsdk:"description"`
+}
+
+type FilterSharedForwardModel struct {
+	Address   string   `tfsdk:"address"`
+	FilterIDs []string `tfsdk:"filter_ids"`
+}
+
+var filterConflictAttrTypes = map[string]attr.Type{
+	"adding_filter_id":   types.StringType,
+	"removing_filter_id": types.StringType,
+	"label_id":           types.StringType,
+	"overlap":            types.StringType,
+}
+
+var filterDuplicateAttrTypes = map[string]attr.Type{
+	"filter_ids": types.ListType{ElemType: types.StringType},
+}
+
+var filterSubsumedAttrTypes = map[string]attr.Type{
+	"filter_id":   types.StringType,
+	"subsumed_by": types.StringType,
+	"description": types.StringType,
+}
+
+var filterSharedForwardAttrTypes = map[string]attr.Type{
+	"address":    types.StringType,
+	"filter_ids": types.ListType{ElemType: types.StringType},
+}
+
+// Values of the overlap attribute of a conflict.
+const (
+	filterOverlapEqual    = "equal"
+	filterOverlapSubset   = "subset"
+	filterOverlapPossible = "possible"
+)
+
+func (d *FilterAnalysisDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_filter_analysis"
+}
+
+func (d *FilterAnalysisDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Looks for problems across Gmail filters, including proposed ones that do not exist yet: filters with conflicting label actions, duplicates, filters made redundant by a more general one with the same action, and filters forwarding to the same address. " +
+			"Criteria are compared without looking at any messages, so conflicts between filters whose criteria cannot be compared are reported with overlap set to possible.",
+		Attributes: map[string]schema.Attribute{
+			"filter_ids": schema.SetAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The IDs of the account's filters to analyze. Defaults to all filters; set to an empty set to only analyze the filters given in filters.",
+			},
+			"filters": proposedFiltersAttribute("Filters that do not exist yet, analyzed together with the account's filters"),
+			"conflicts": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "Pairs of filters where one adds a label that the other removes and a message may match both",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"adding_filter_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the filter that adds the label",
+						},
+						"removing_filter_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the filter that removes the label",
+						},
+						"label_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the label",
+						},
+						"overlap": schema.StringAttribute{
+							Computed:    true,
+							Description: "How the criteria of the two filters relate: equal, subset (one is more general than the other) or possible (they may overlap)",
+						},
+					},
+				},
+			},
+			"duplicates": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "Groups of filters with the same action and equivalent criteria, e.g. differing only in case or term order",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"filter_ids": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "The IDs of the duplicate filters",
+						},
+					},
+				},
+			},
+			"subsumed": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "Filters whose criteria are strictly narrower than those of another filter with the same action, which makes them redundant",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"filter_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the redundant filter",
+						},
+						"subsumed_by": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the more general filter",
+						},
+						"description": schema.StringAttribute{
+							Computed:    true,
+							Description: "The criteria of both filters, for humans",
+						},
+					},
+				},
+			},
+			"shared_forwards": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "Forwarding addresses used by more than one filter",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"address": schema.StringAttribute{
+							Computed:    true,
+							Description: "The forwarding address",
+						},
+						"filter_ids": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "The IDs of the filters forwarding to the address",
+						},
+					},
+				},
+			},
+			"issue_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The total number of conflicts, duplicate groups, subsumed filters and shared forwarding addresses",
+			},
+		},
+	}
+}
+
+func (d *FilterAnalysisDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Data Source Configure Type", "Expected *Config")
+		return
+	}
+	d.config = config
+}
+
+func (d *FilterAnalysisDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data FilterAnalysisDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filters, err := listFilters(d.config.gmailService)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list filters", err.Error())
+		return
+	}
+
+	if !data.FilterIDs.IsNull() {
+		var ids []string
+		resp.Diagnostics.Append(data.FilterIDs.ElementsAs(ctx, &ids, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		byID := map[string]*gmail.Filter{}
+		for _, f := range filters {
+			byID[f.Id] = f
+		}
+		filters = nil
+		for _, id := range ids {
+			f, ok := byID[id]
+			if !ok {
+				resp.Diagnostics.AddAttributeError(path.Root("filter_ids"), "Filter not found",
+					fmt.Sprintf("No filter with ID %q found", id))
+				continue
+			}
+			filters = append(filters, f)
+		}
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	proposed := proposedFilters(ctx, data.Filters, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	filters = append(filters, proposed...)
+
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].Id < filters[j].Id
+	})
+	analysis := analyzeFilters(filters)
+
+	data.Conflicts = listValueFrom(ctx, filterConflictAttrTypes, analysis.conflicts, &resp.Diagnostics)
+	data.Duplicates = listValueFrom(ctx, filterDuplicateAttrTypes, analysis.duplicates, &resp.Diagnostics)
+	data.Subsumed = listValueFrom(ctx, filterSubsumedAttrTypes, analysis.subsumed, &resp.Diagnostics)
+	data.SharedForwards = listValueFrom(ctx, filterSharedForwardAttrTypes, analysis.sharedForwards, &resp.Diagnostics)
+	data.IssueCount = types.Int64Value(int64(len(analysis.conflicts) + len(analysis.duplicates) + len(analysis.subsumed) + len(analysis.sharedForwards)))
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func listValueFrom(ctx context.Context, attrTypes map[string]attr.Type, elements any, diags *diag.Diagnostics) types.List {
+	list, d := types.ListValueFrom(ctx, types.ObjectType{AttrTypes: attrTypes}, elements)
+	diags.Append(d...)
+	return list
+}
+
+type filterAnalysis struct {
+	conflicts      []FilterConflictModel
+	duplicates     []FilterDuplicateModel
+	subsumed       []FilterSubsumedModel
+	sharedForwards []FilterSharedForwardModel
+}
+
+// analyzeFilters compares every pair of filters, which must be ordered by ID.
+// Filters with the same action and equivalent criteria are reported once as
+// a group of duplicates and not again as conflicting or subsumed.
+func analyzeFilters(filters []*gmail.Filter) filterAnalysis {
+	analysis := filterAnalysis{
+		conflicts:      []FilterConflictModel{},
+		duplicates:     []FilterDuplicateModel{},
+		subsumed:       []FilterSubsumedModel{},
+		sharedForwards: []FilterSharedForwardModel{},
+	}
+
+	// Keep the first filter of each group of duplicates.
+	var unique []*gmail.Filter
+	groups := map[*gmail.Filter][]string{}
+	for _, f := range filters {
+		first := f
+		for _, u := range unique {
+			if filterActionKey(u) == filterActionKey(f) && criteriaEquivalent(u.Criteria, f.Criteria) {
+				first = u
+				break
+			}
+		}
+		if first == f {
+			unique = append(unique, f)
+		}
+		groups[first] = append(groups[first], f.Id)
+	}
+	for _, f := range unique {
+		if ids := groups[f]; len(ids) > 1 {
+			analysis.duplicates = append(analysis.duplicates, FilterDuplicateModel{FilterIDs: ids})
+		}
+	}
+
+	for _, a := range unique {
+		for _, b := range unique {
+			if a == b {
+				continue
+			}
+			analysis.conflicts = append(analysis.conflicts, labelConflicts(a, b)...)
+			if filterActionKey(a) == filterActionKey(b) && criteriaSubsumes(b.Criteria, a.Criteria) && !criteriaEquivalent(a.Criteria, b.Criteria) {
+				analysis.subsumed = append(analysis.subsumed, FilterSubsumedModel{
+					FilterID:    a.Id,
+					SubsumedBy:  b.Id,
+					Description: fmt.Sprintf("%s is narrower than %s", describeFilterCriteria(a.Criteria), describeFilterCriteria(b.Criteria)),
+				})
+			}
+		}
+	}
+
+	forwards := map[string][]string{}
+	var addresses []string
+	for _, f := range filters {
+		if f.Action == nil || f.Action.Forward == "" {
+			continue
+		}
+		address := strings.ToLower(f.Action.Forward)
+		if _, ok := forwards[address]; !ok {
+			addresses = append(addresses, address)
+		}
+		forwards[address] = append(forwards[address], f.Id)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		if ids := forwards[address]; len(ids) > 1 {
+			analysis.sharedForwards = append(analysis.sharedForwards, FilterSharedForwardModel{Address: address, FilterIDs: ids})
+		}
+	}
+
+	return analysis
+}
+
+// labelConflicts returns the labels that adding adds and removing removes,
+// when a message may match both filters.
+func labelConflicts(adding, removing *gmail.Filter) []FilterConflictModel {
+	if adding.Action == nil || removing.Action == nil || criteriaDisjoint(adding.Criteria, removing.Criteria) {
+		return nil
+	}
+
+	overlap := filterOverlapPossible
+	switch {
+	case criteriaEquivalent(adding.Criteria, removing.Criteria):
+		overlap = filterOverlapEqual
+	case criteriaSubsumes(adding.Criteria, removing.Criteria), criteriaSubsumes(removing.Criteria, adding.Criteria):
+		overlap = filterOverlapSubset
+	}
+
+	var conflicts []FilterConflictModel
+	for _, labelID := range sortedCopy(adding.Action.AddLabelIds) {
+		if containsString(removing.Action.RemoveLabelIds, labelID) {
+			conflicts = append(conflicts, FilterConflictModel{
+				AddingFilterID:   adding.Id,
+				RemovingFilterID: removing.Id,
+				LabelID:          labelID,
+				Overlap:          overlap,
+			})
+		}
+	}
+	return conflicts
+}
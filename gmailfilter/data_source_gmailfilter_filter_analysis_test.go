@@ -0,0 +1,26 @@
+package gmailfilter
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestAnalyzeFiltersDuplicates(t *testing.T) {
+	archive := &gmail.FilterAction{RemoveLabelIds: []string{"INBOX"}}
+	filters := []*gmail.Filter{
+		{Id: "a", Criteria: &gmail.FilterCriteria{From: "news@x.com"}, Action: archive},
+		{Id: "b", Criteria: &gmail.FilterCriteria{From: "News@X.com "}, Action: archive},
+		{Id: "c", Criteria: &gmail.FilterCriteria{From: "news@x.com"}, Action: &gmail.FilterAction{AddLabelIds: []string{"STARRED"}}},
+		{Id: "d", Criteria: &gmail.FilterCriteria{From: "news@x.com", Subject: "weekly"}, Action: archive},
+	}
+
+	analysis := analyzeFilters(filters)
+	if want := []FilterDuplicateModel{{FilterIDs: []string{"a", "b"}}}; !reflect.DeepEqual(analysis.duplicates, want) {
+		t.Errorf("duplicates = %v, want %v", analysis.duplicates, want)
+	}
+	if len(analysis.subsumed) != 1 || analysis.subsumed[0].FilterID != "d" || analysis.subsumed[0].SubsumedBy != "a" {
+		t.Errorf("subsumed = %v, want d subsumed by a", analysis.subsumed)
+	}
+}
@@ -0,0 +1,255 @@
+package gmailfilter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/gmail/v1"
+)
+
+var _ datasource.DataSource = &FiltersDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &FiltersDataSource{}
+
+func NewFiltersDataSource() datasource.DataSource {
+	return &FiltersDataSource{}
+}
+
+type FiltersDataSource struct {
+	config *Config
+}
+
+type FiltersDataSourceModel struct {
+	FromContains types.String `tfsdk:"from_contains"`
+	QueryRegex   types.String `tfsdk:"query_regex"`
+	LabelID      types.String `tfsdk:"label_id"`
+	LabelName    types.String `tfsdk:"label_name"`
+	HasForward   types.Bool   `tfsdk:"has_forward"`
+	IDs          types.List   `tfsdk:"ids"`
+	Filters      types.List   `tfsdk:"filters"`
+}
+
+// filterDataSourceAttrTypes are the attribute types of a single filter in data
+// sources that return lists of filters, modelled by FilterDataSourceModel.
+var filterDataSourceAttrTypes = map[string]attr.Type{
+	"id":       types.StringType,
+	"action":   types.ObjectType{AttrTypes: filterActionAttrTypes},
+	"criteria": types.ObjectType{AttrTypes: filterCriteriaAttrTypes},
+}
+
+func (d *FiltersDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_filters"
+}
+
+func (d *FiltersDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Lists Gmail filters, optionally narrowed down by criteria and action. All given conditions must match.",
+		Attributes: map[string]schema.Attribute{
+			"from_contains": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only list filters whose from criteria contains this string, ignoring case",
+			},
+			"query_regex": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only list filters whose query criteria matches this regular expression",
+			},
+			"label_id": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only list filters that add or remove the label with this ID",
+			},
+			"label_name": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only list filters that add or remove the label with this name, ignoring case",
+			},
+			"has_forward": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Only list filters that forward messages (true) or that do not (false)",
+			},
+			"ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Description: "The IDs of the matching filters",
+			},
+			"filters": schema.ListNestedAttribute{
+				Computed:     true,
+				Description:  "The matching filters",
+				NestedObject: filterDataSourceNestedObject(),
+			},
+		},
+	}
+}
+
+func (d *FiltersDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Data Source Configure Type", "Expected *Config")
+		return
+	}
+	d.config = config
+}
+
+func (d *FiltersDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var data FiltersDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !data.QueryRegex.IsNull() && !data.QueryRegex.IsUnknown() {
+		if _, err := regexp.Compile(data.QueryRegex.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("query_regex"), "Invalid regular expression", err.Error())
+		}
+	}
+}
+
+func (d *FiltersDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data FiltersDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var queryRegex *regexp.Regexp
+	if !data.QueryRegex.IsNull() {
+		var err error
+		if queryRegex, err = regexp.Compile(data.QueryRegex.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("query_regex"), "Invalid regular expression", err.Error())
+			return
+		}
+	}
+
+	var labelIDs []string
+	if !data.LabelID.IsNull() {
+		labelIDs = append(labelIDs, data.LabelID.ValueString())
+	}
+	if !data.LabelName.IsNull() {
+		res, err := d.config.gmailService.Users.Labels.List(gmailUser).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to list labels", err.Error())
+			return
+		}
+		found := false
+		for _, label := range res.Labels {
+			if strings.EqualFold(label.Name, data.LabelName.ValueString()) {
+				labelIDs = append(labelIDs, label.Id)
+				found = true
+			}
+		}
+		if !found {
+			resp.Diagnostics.AddAttributeError(path.Root("label_name"), "Label not found",
+				fmt.Sprintf("No label with name %q found", data.LabelName.ValueString()))
+			return
+		}
+	}
+
+	filters, err := listFilters(d.config.gmailService)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list filters", err.Error())
+		return
+	}
+
+	var matches []*gmail.Filter
+	for _, f := range filters {
+		action, criteria := f.Action, f.Criteria
+		if action == nil {
+			action = &gmail.FilterAction{}
+		}
+		if criteria == nil {
+			criteria = &gmail.FilterCriteria{}
+		}
+
+		if !data.FromContains.IsNull() && !strings.Contains(strings.ToLower(criteria.From), strings.ToLower(data.FromContains.ValueString())) {
+			continue
+		}
+		if queryRegex != nil && !queryRegex.MatchString(criteria.Query) {
+			continue
+		}
+		if !data.HasForward.IsNull() && (action.Forward != "") != data.HasForward.ValueBool() {
+			continue
+		}
+		if !allLabelsReferenced(f, labelIDs) {
+			continue
+		}
+		matches = append(matches, f)
+	}
+
+	ids, list := flattenFilterDataSourceList(ctx, matches, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.IDs = ids
+	data.Filters = list
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func allLabelsReferenced(f *gmail.Filter, labelIDs []string) bool {
+	for _, id := range labelIDs {
+		if !filterReferencesLabel(f, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterDataSourceNestedObject describes a single filter in data sources that
+// return lists of filters.
+func filterDataSourceNestedObject() schema.NestedAttributeObject {
+	return schema.NestedAttributeObject{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of the filter",
+			},
+			"action": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "Action that the filter performs",
+				Attributes:  filterActionDataSourceAttributes(false, true),
+			},
+			"criteria": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "The criteria that a message should match to apply the filter",
+				Attributes:  filterCriteriaDataSourceAttributes(false, true),
+			},
+		},
+	}
+}
+
+// flattenFilterDataSourceList converts filters, ordered by ID, into a list of
+// IDs and a list of filter objects matching filterDataSourceNestedObject.
+func flattenFilterDataSourceList(ctx context.Context, filters []*gmail.Filter, diags *diag.Diagnostics) (types.List, types.List) {
+	sorted := append([]*gmail.Filter(nil), filters...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Id < sorted[j].Id
+	})
+
+	ids := []string{}
+	entries := []FilterDataSourceModel{}
+	for _, f := range sorted {
+		action, criteria, d := flattenFilterDataSource(ctx, f)
+		diags.Append(d...)
+		ids = append(ids, f.Id)
+		entries = append(entries, FilterDataSourceModel{
+			ID:       types.StringValue(f.Id),
+			Action:   action,
+			Criteria: criteria,
+		})
+	}
+
+	idList, d := types.ListValueFrom(ctx, types.StringType, ids)
+	diags.Append(d...)
+	filterList, d := types.ListValueFrom(ctx, types.ObjectType{AttrTypes: filterDataSourceAttrTypes}, entries)
+	diags.Append(d...)
+	return idList, filterList
+}
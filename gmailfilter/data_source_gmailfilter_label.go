@@ -3,13 +3,22 @@ package gmailfilter
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/gmail/v1"
 )
 
 var _ datasource.DataSource = &LabelDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &LabelDataSource{}
 
 func NewLabelDataSource() datasource.DataSource {
 	return &LabelDataSource{}
@@ -22,6 +31,10 @@ type LabelDataSource struct {
 type LabelDataSourceModel struct {
 	ID                    types.String `tfsdk:"id"`
 	Name                  types.String `tfsdk:"name"`
+	NameRegex             types.String `tfsdk:"name_regex"`
+	CaseInsensitive       types.Bool   `tfsdk:"case_insensitive"`
+	AllowMissing          types.Bool   `tfsdk:"allow_missing"`
+	Exists                types.Bool   `tfsdk:"exists"`
 	BackgroundColor       types.String `tfsdk:"background_color"`
 	TextColor             types.String `tfsdk:"text_color"`
 	LabelListVisibility   types.String `tfsdk:"label_list_visibility"`
@@ -31,24 +44,59 @@ type LabelDataSourceModel struct {
 	ThreadsTotal          types.Int64  `tfsdk:"threads_total"`
 	ThreadsUnread         types.Int64  `tfsdk:"threads_unread"`
 	Type                  types.String `tfsdk:"type"`
+	FilterIDs             types.List   `tfsdk:"filter_ids"`
+	Filters               types.List   `tfsdk:"filters"`
 }
 
+type LabelDataSourceFilterModel struct {
+	ID   types.String `tfsdk:"id"`
+	Role types.String `tfsdk:"role"`
+}
+
+var labelDataSourceFilterAttrTypes = map[string]attr.Type{
+	"id":   types.StringType,
+	"role": types.StringType,
+}
+
+const (
+	labelFilterRoleAdd    = "add"
+	labelFilterRoleRemove = "remove"
+)
+
 func (d *LabelDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_label"
 }
 
 func (d *LabelDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		Description: "Reads a Gmail label by name",
+		Description: "Reads a Gmail label by ID, by name or by a regular expression on the name, along with the filters that add or remove it. Exactly one of id, name and name_regex must be set.",
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
+				Optional:    true,
 				Computed:    true,
 				Description: "The immutable ID of the label",
 			},
 			"name": schema.StringAttribute{
-				Required:    true,
+				Optional:    true,
+				Computed:    true,
 				Description: "The display name of the label",
 			},
+			"name_regex": schema.StringAttribute{
+				Optional:    true,
+				Description: "A regular expression the display name must match. It must match exactly one label.",
+			},
+			"case_insensitive": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether name and name_regex are matched case-insensitively",
+			},
+			"allow_missing": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Set exists to false instead of failing when no label matches",
+			},
+			"exists": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether a matching label was found",
+			},
 			"background_color": schema.StringAttribute{
 				Computed:    true,
 				Description: "The background color represented as hex string #RRGGBB",
@@ -82,8 +130,33 @@ func (d *LabelDataSource) Schema(ctx context.Context, req datasource.SchemaReque
 				Description: "The number of unread threads with the label",
 			},
 			"type": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The owner type for the label (user or system). When set, only labels of this type are considered.",
+				Validators: []validator.String{
+					stringOneOf("user", "system"),
+				},
+			},
+			"filter_ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Description: "The IDs of the filters that add or remove the label",
+			},
+			"filters": schema.ListNestedAttribute{
 				Computed:    true,
-				Description: "The owner type for the label (user or system)",
+				Description: "The filters that add or remove the label, with one entry per role. A filter that both adds and removes the label appears twice.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the filter",
+						},
+						"role": schema.StringAttribute{
+							Computed:    true,
+							Description: "Whether the filter adds (add) or removes (remove) the label",
+						},
+					},
+				},
 			},
 		},
 	}
@@ -101,6 +174,30 @@ func (d *LabelDataSource) Configure(ctx context.Context, req datasource.Configur
 	d.config = config
 }
 
+func (d *LabelDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var data LabelDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	set := 0
+	for _, v := range []types.String{data.ID, data.Name, data.NameRegex} {
+		if !v.IsNull() {
+			set++
+		}
+	}
+	if set != 1 {
+		resp.Diagnostics.AddError("Invalid label lookup", "Exactly one of id, name and name_regex must be set")
+	}
+
+	if !data.NameRegex.IsNull() && !data.NameRegex.IsUnknown() {
+		if _, err := regexp.Compile(data.NameRegex.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("name_regex"), "Invalid regular expression", err.Error())
+		}
+	}
+}
+
 func (d *LabelDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
 	var data LabelDataSourceModel
 	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
@@ -108,37 +205,167 @@ func (d *LabelDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
-	res, err := d.config.gmailService.Users.Labels.List(gmailUser).Do()
+	id, desc, err := d.findLabelID(data)
 	if err != nil {
-		resp.Diagnostics.AddError("Failed to list labels", err.Error())
+		resp.Diagnostics.AddError("Failed to find label", err.Error())
 		return
 	}
 
-	// Find label by name
-	for _, label := range res.Labels {
-		if label.Name == data.Name.ValueString() {
-			data.ID = types.StringValue(label.Id)
-			data.Name = types.StringValue(label.Name)
-			data.LabelListVisibility = types.StringValue(label.LabelListVisibility)
-			data.MessageListVisibility = types.StringValue(label.MessageListVisibility)
-			data.MessagesTotal = types.Int64Value(label.MessagesTotal)
-			data.MessagesUnread = types.Int64Value(label.MessagesUnread)
-			data.ThreadsTotal = types.Int64Value(label.ThreadsTotal)
-			data.ThreadsUnread = types.Int64Value(label.ThreadsUnread)
-			data.Type = types.StringValue(label.Type)
-
-			if label.Color != nil {
-				data.BackgroundColor = types.StringValue(label.Color.BackgroundColor)
-				data.TextColor = types.StringValue(label.Color.TextColor)
-			} else {
-				data.BackgroundColor = types.StringNull()
-				data.TextColor = types.StringNull()
-			}
+	var label *gmail.Label
+	if id != "" {
+		label, err = d.config.gmailService.Users.Labels.Get(gmailUser, id).Do()
+		if err != nil && !isNotFoundError(err) {
+			resp.Diagnostics.AddError("Failed to read label", err.Error())
+			return
+		}
+		if label != nil && !data.Type.IsNull() && label.Type != data.Type.ValueString() {
+			label = nil
+		}
+	}
 
-			resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	if label == nil {
+		if !data.AllowMissing.ValueBool() {
+			resp.Diagnostics.AddError("Label not found", fmt.Sprintf("No label %s found", desc))
 			return
 		}
+		data.Exists = types.BoolValue(false)
+		data.BackgroundColor = types.StringNull()
+		data.TextColor = types.StringNull()
+		data.LabelListVisibility = types.StringNull()
+		data.MessageListVisibility = types.StringNull()
+		data.MessagesTotal = types.Int64Null()
+		data.MessagesUnread = types.Int64Null()
+		data.ThreadsTotal = types.Int64Null()
+		data.ThreadsUnread = types.Int64Null()
+		data.FilterIDs = types.ListNull(types.StringType)
+		data.Filters = types.ListNull(types.ObjectType{AttrTypes: labelDataSourceFilterAttrTypes})
+		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+		return
 	}
 
-	resp.Diagnostics.AddError("Label not found", fmt.Sprintf("No label with name %q found", data.Name.ValueString()))
+	data.Exists = types.BoolValue(true)
+	data.ID = types.StringValue(label.Id)
+	data.Name = types.StringValue(label.Name)
+	data.LabelListVisibility = types.StringValue(label.LabelListVisibility)
+	data.MessageListVisibility = types.StringValue(label.MessageListVisibility)
+	data.MessagesTotal = types.Int64Value(label.MessagesTotal)
+	data.MessagesUnread = types.Int64Value(label.MessagesUnread)
+	data.ThreadsTotal = types.Int64Value(label.ThreadsTotal)
+	data.ThreadsUnread = types.Int64Value(label.ThreadsUnread)
+	data.Type = types.StringValue(label.Type)
+
+	if label.Color != nil {
+		data.BackgroundColor = types.StringValue(label.Color.BackgroundColor)
+		data.TextColor = types.StringValue(label.Color.TextColor)
+	} else {
+		data.BackgroundColor = types.StringNull()
+		data.TextColor = types.StringNull()
+	}
+
+	filters, err := listFilters(d.config.gmailService)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list filters", err.Error())
+		return
+	}
+	filterIDs, filterRoles := labelFilterReferences(filters, label.Id)
+
+	var diags diag.Diagnostics
+	data.FilterIDs, diags = types.ListValueFrom(ctx, types.StringType, filterIDs)
+	resp.Diagnostics.Append(diags...)
+	data.Filters, diags = types.ListValueFrom(ctx, types.ObjectType{AttrTypes: labelDataSourceFilterAttrTypes}, filterRoles)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// labelFilterReferences returns the IDs of the filters that add or remove
+// labelID, and one entry per filter and role. Both are ordered by filter ID.
+func labelFilterReferences(filters []*gmail.Filter, labelID string) ([]string, []LabelDataSourceFilterModel) {
+	sorted := append([]*gmail.Filter(nil), filters...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Id < sorted[j].Id
+	})
+
+	ids := []string{}
+	roles := []LabelDataSourceFilterModel{}
+	for _, f := range sorted {
+		if !filterReferencesLabel(f, labelID) {
+			continue
+		}
+		ids = append(ids, f.Id)
+		if containsString(f.Action.AddLabelIds, labelID) {
+			roles = append(roles, LabelDataSourceFilterModel{
+				ID:   types.StringValue(f.Id),
+				Role: types.StringValue(labelFilterRoleAdd),
+			})
+		}
+		if containsString(f.Action.RemoveLabelIds, labelID) {
+			roles = append(roles, LabelDataSourceFilterModel{
+				ID:   types.StringValue(f.Id),
+				Role: types.StringValue(labelFilterRoleRemove),
+			})
+		}
+	}
+	return ids, roles
+}
+
+// findLabelID returns the ID of the label matching the lookup in data, or an
+// empty string when there is none. desc describes the lookup for messages.
+func (d *LabelDataSource) findLabelID(data LabelDataSourceModel) (id string, desc string, err error) {
+	if !data.ID.IsNull() {
+		return data.ID.ValueString(), fmt.Sprintf("with ID %q", data.ID.ValueString()), nil
+	}
+
+	var match func(string) bool
+	if !data.NameRegex.IsNull() {
+		expr := data.NameRegex.ValueString()
+		desc = fmt.Sprintf("matching %q", expr)
+		if data.CaseInsensitive.ValueBool() {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return "", desc, err
+		}
+		match = re.MatchString
+	} else {
+		name := data.Name.ValueString()
+		desc = fmt.Sprintf("with name %q", name)
+		match = func(s string) bool {
+			if data.CaseInsensitive.ValueBool() {
+				return strings.EqualFold(s, name)
+			}
+			return s == name
+		}
+	}
+
+	res, err := d.config.gmailService.Users.Labels.List(gmailUser).Do()
+	if err != nil {
+		return "", desc, err
+	}
+
+	var matches []*gmail.Label
+	for _, label := range res.Labels {
+		if !data.Type.IsNull() && label.Type != data.Type.ValueString() {
+			continue
+		}
+		if match(label.Name) {
+			matches = append(matches, label)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", desc, nil
+	case 1:
+		return matches[0].Id, desc, nil
+	}
+	names := make([]string, len(matches))
+	for i, label := range matches {
+		names[i] = fmt.Sprintf("%s (%s)", label.Name, label.Id)
+	}
+	return "", desc, fmt.Errorf("%d labels %s found, the lookup must match exactly one: %s", len(matches), desc, strings.Join(names, ", "))
 }
@@ -0,0 +1,98 @@
+package gmailfilter
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ datasource.DataSource = &LabelStatsDataSource{}
+
+func NewLabelStatsDataSource() datasource.DataSource {
+	return &LabelStatsDataSource{}
+}
+
+type LabelStatsDataSource struct {
+	config *Config
+}
+
+type LabelStatsDataSourceModel struct {
+	ID             types.String `tfsdk:"id"`
+	Name           types.String `tfsdk:"name"`
+	MessagesTotal  types.Int64  `tfsdk:"messages_total"`
+	MessagesUnread types.Int64  `tfsdk:"messages_unread"`
+	ThreadsTotal   types.Int64  `tfsdk:"threads_total"`
+	ThreadsUnread  types.Int64  `tfsdk:"threads_unread"`
+}
+
+func (d *LabelStatsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_label_stats"
+}
+
+func (d *LabelStatsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads the message and thread counts of a Gmail label",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Required:    true,
+				Description: "The ID of the label",
+			},
+			"name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The display name of the label",
+			},
+			"messages_total": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The total number of messages with the label",
+			},
+			"messages_unread": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of unread messages with the label",
+			},
+			"threads_total": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The total number of threads with the label",
+			},
+			"threads_unread": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of unread threads with the label",
+			},
+		},
+	}
+}
+
+func (d *LabelStatsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Data Source Configure Type", "Expected *Config")
+		return
+	}
+	d.config = config
+}
+
+func (d *LabelStatsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data LabelStatsDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	label, err := d.config.gmailService.Users.Labels.Get(gmailUser, data.ID.ValueString()).Do()
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read label", err.Error())
+		return
+	}
+
+	data.Name = types.StringValue(label.Name)
+	data.MessagesTotal = types.Int64Value(label.MessagesTotal)
+	data.MessagesUnread = types.Int64Value(label.MessagesUnread)
+	data.ThreadsTotal = types.Int64Value(label.ThreadsTotal)
+	data.ThreadsUnread = types.Int64Value(label.ThreadsUnread)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
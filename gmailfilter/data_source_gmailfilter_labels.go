@@ -0,0 +1,221 @@
+package gmailfilter
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ datasource.DataSource = &LabelsDataSource{}
+
+func NewLabelsDataSource() datasource.DataSource {
+	return &LabelsDataSource{}
+}
+
+type LabelsDataSource struct {
+	config *Config
+}
+
+type LabelsDataSourceModel struct {
+	Type       types.String `tfsdk:"type"`
+	NamePrefix types.String `tfsdk:"name_prefix"`
+	NameRegex  types.String `tfsdk:"name_regex"`
+	Labels     types.List   `tfsdk:"labels"`
+	ByName     types.Map    `tfsdk:"by_name"`
+}
+
+type LabelsDataSourceLabelModel struct {
+	ID                    types.String `tfsdk:"id"`
+	Name                  types.String `tfsdk:"name"`
+	Type                  types.String `tfsdk:"type"`
+	BackgroundColor       types.String `tfsdk:"background_color"`
+	TextColor             types.String `tfsdk:"text_color"`
+	LabelListVisibility   types.String `tfsdk:"label_list_visibility"`
+	MessageListVisibility types.String `tfsdk:"message_list_visibility"`
+	Parent                types.String `tfsdk:"parent"`
+	PathParts             types.List   `tfsdk:"path_parts"`
+}
+
+var labelsDataSourceLabelAttrTypes = map[string]attr.Type{
+	"id":                      types.StringType,
+	"name":                    types.StringType,
+	"type":                    types.StringType,
+	"background_color":        types.StringType,
+	"text_color":              types.StringType,
+	"label_list_visibility":   types.StringType,
+	"message_list_visibility": types.StringType,
+	"parent":                  types.StringType,
+	"path_parts":              types.ListType{ElemType: types.StringType},
+}
+
+func (d *LabelsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_labels"
+}
+
+func (d *LabelsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Lists Gmail labels",
+		Attributes: map[string]schema.Attribute{
+			"type": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only list labels of this owner type (user or system)",
+				Validators: []validator.String{
+					stringOneOf("user", "system"),
+				},
+			},
+			"name_prefix": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only list labels whose name starts with this prefix",
+			},
+			"name_regex": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only list labels whose name matches this regular expression",
+			},
+			"labels": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The matching labels, ordered by name",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The immutable ID of the label",
+						},
+						"name": schema.StringAttribute{
+							Computed:    true,
+							Description: "The display name of the label",
+						},
+						"type": schema.StringAttribute{
+							Computed:    true,
+							Description: "The owner type for the label (user or system)",
+						},
+						"background_color": schema.StringAttribute{
+							Computed:    true,
+							Description: "The background color represented as hex string #RRGGBB",
+						},
+						"text_color": schema.StringAttribute{
+							Computed:    true,
+							Description: "The text color of the label, represented as hex string",
+						},
+						"label_list_visibility": schema.StringAttribute{
+							Computed:    true,
+							Description: "The visibility of the label in the label list in the Gmail web interface",
+						},
+						"message_list_visibility": schema.StringAttribute{
+							Computed:    true,
+							Description: "The visibility of messages with this label in the message list",
+						},
+						"parent": schema.StringAttribute{
+							Computed:    true,
+							Description: "The full name of the label this one is nested under, or null for a top-level label",
+						},
+						"path_parts": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "The components of the label name, from the top-level label down to this one",
+						},
+					},
+				},
+			},
+			"by_name": schema.MapAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Description: "The IDs of the matching labels, keyed by label name",
+			},
+		},
+	}
+}
+
+func (d *LabelsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Data Source Configure Type", "Expected *Config")
+		return
+	}
+	d.config = config
+}
+
+func (d *LabelsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data LabelsDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var re *regexp.Regexp
+	if !data.NameRegex.IsNull() {
+		var err error
+		if re, err = regexp.Compile(data.NameRegex.ValueString()); err != nil {
+			resp.Diagnostics.AddError("Invalid regular expression", err.Error())
+			return
+		}
+	}
+
+	res, err := d.config.gmailService.Users.Labels.List(gmailUser).Do()
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list labels", err.Error())
+		return
+	}
+
+	labels := []LabelsDataSourceLabelModel{}
+	byName := map[string]string{}
+	for _, label := range res.Labels {
+		if !data.Type.IsNull() && label.Type != data.Type.ValueString() {
+			continue
+		}
+		if !strings.HasPrefix(label.Name, data.NamePrefix.ValueString()) {
+			continue
+		}
+		if re != nil && !re.MatchString(label.Name) {
+			continue
+		}
+
+		parts := strings.Split(label.Name, "/")
+		entry := LabelsDataSourceLabelModel{
+			ID:                    types.StringValue(label.Id),
+			Name:                  types.StringValue(label.Name),
+			Type:                  types.StringValue(label.Type),
+			BackgroundColor:       types.StringNull(),
+			TextColor:             types.StringNull(),
+			LabelListVisibility:   stringOrNull(label.LabelListVisibility),
+			MessageListVisibility: stringOrNull(label.MessageListVisibility),
+			Parent:                types.StringNull(),
+			PathParts:             convertStringSliceToList(ctx, parts),
+		}
+		if len(parts) > 1 {
+			entry.Parent = types.StringValue(strings.Join(parts[:len(parts)-1], "/"))
+		}
+		if label.Color != nil {
+			entry.BackgroundColor = types.StringValue(label.Color.BackgroundColor)
+			entry.TextColor = types.StringValue(label.Color.TextColor)
+		}
+		labels = append(labels, entry)
+		byName[label.Name] = label.Id
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name.ValueString() < labels[j].Name.ValueString()
+	})
+
+	labelList, diags := types.ListValueFrom(ctx, types.ObjectType{AttrTypes: labelsDataSourceLabelAttrTypes}, labels)
+	resp.Diagnostics.Append(diags...)
+	byNameMap, diags := types.MapValueFrom(ctx, types.StringType, byName)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	data.Labels = labelList
+	data.ByName = byNameMap
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
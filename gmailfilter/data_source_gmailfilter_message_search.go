@@ -0,0 +1,289 @@
+package gmailfilter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"google.golang.org/api/gmail/v1"
+)
+
+var _ datasource.DataSource = &MessageSearchDataSource{}
+var _ datasource.DataSourceWithValidateConfig = &MessageSearchDataSource{}
+
+const (
+	defaultMessageSearchSampleSize      = 10
+	maxMessageSearchSampleSize          = 100
+	defaultMessageSearchMatchCountLimit = 5000
+)
+
+func NewMessageSearchDataSource() datasource.DataSource {
+	return &MessageSearchDataSource{}
+}
+
+type MessageSearchDataSource struct {
+	config *Config
+}
+
+type MessageSearchDataSourceModel struct {
+	Query               types.String `tfsdk:"query"`
+	Criteria            types.Object `tfsdk:"criteria"`
+	SampleSize          types.Int64  `tfsdk:"sample_size"`
+	MatchCountLimit     types.Int64  `tfsdk:"match_count_limit"`
+	CompiledQuery       types.String `tfsdk:"compiled_query"`
+	MatchCount          types.Int64  `tfsdk:"match_count"`
+	MatchCountTruncated types.Bool   `tfsdk:"match_count_truncated"`
+	Messages            types.List   `tfsdk:"messages"`
+}
+
+type MessageSearchMessageModel struct {
+	ID       string `tfsdk:"id"`
+	ThreadID string `tfsdk:"thread_id"`
+	From     string `tfsdk:"from"`
+	Subject  string `tfsdk:"subject"`
+	Date     string `tfsdk:"date"`
+}
+
+var messageSearchMessageAttrTypes = map[string]attr.Type{
+	"id":        types.StringType,
+	"thread_id": types.StringType,
+	"from":      types.StringType,
+	"subject":   types.StringType,
+	"date":      types.StringType,
+}
+
+func (d *MessageSearchDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_message_search"
+}
+
+func (d *MessageSearchDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Searches the mailbox with a Gmail query, filter criteria or both, e.g. to see how many existing messages a new filter would catch. At least one of query and criteria must be set.",
+		Attributes: map[string]schema.Attribute{
+			"query": schema.StringAttribute{
+				Optional:    true,
+				Description: "A Gmail search query",
+			},
+			"criteria": schema.SingleNestedAttribute{
+				Optional:    true,
+				Description: "Filter criteria, compiled into the equivalent query. Combined with query when both are set.",
+				Attributes:  filterCriteriaDataSourceAttributes(true, false),
+			},
+			"sample_size": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The number of matching messages to return details for, at most 100. Defaults to 10.",
+			},
+			"match_count_limit": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Stop counting matching messages after this many. Defaults to 5000.",
+			},
+			"compiled_query": schema.StringAttribute{
+				Computed:    true,
+				Description: "The query that was sent to Gmail",
+			},
+			"match_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of matching messages, up to match_count_limit",
+			},
+			"match_count_truncated": schema.BoolAttribute{
+				Computed:    true,
+				Description: "Whether more than match_count_limit messages match",
+			},
+			"messages": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "A sample of the matching messages, newest first",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the message",
+						},
+						"thread_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the thread the message belongs to",
+						},
+						"from": schema.StringAttribute{
+							Computed:    true,
+							Description: "The From header of the message",
+						},
+						"subject": schema.StringAttribute{
+							Computed:    true,
+							Description: "The Subject header of the message",
+						},
+						"date": schema.StringAttribute{
+							Computed:    true,
+							Description: "The Date header of the message",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *MessageSearchDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Data Source Configure Type", "Expected *Config")
+		return
+	}
+	d.config = config
+}
+
+func (d *MessageSearchDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var data MessageSearchDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.Query.IsNull() && data.Criteria.IsNull() {
+		resp.Diagnostics.AddError("Invalid message search", "At least one of query and criteria must be set")
+	}
+	// An empty search would count every message in the mailbox.
+	if v := data.Query; !v.IsNull() && !v.IsUnknown() && strings.TrimSpace(v.ValueString()) == "" {
+		resp.Diagnostics.AddAttributeError(path.Root("query"), "Invalid query", "query must not be empty")
+	}
+	if !data.Criteria.IsNull() && !data.Criteria.IsUnknown() && criteriaObjectEmpty(data.Criteria) {
+		resp.Diagnostics.AddAttributeError(path.Root("criteria"), "Invalid criteria", "At least one criteria field must be set to a non-empty value")
+	}
+	if v := data.SampleSize; !v.IsNull() && !v.IsUnknown() && (v.ValueInt64() < 0 || v.ValueInt64() > maxMessageSearchSampleSize) {
+		resp.Diagnostics.AddAttributeError(path.Root("sample_size"), "Invalid sample size", "sample_size must be between 0 and 100")
+	}
+	if v := data.MatchCountLimit; !v.IsNull() && !v.IsUnknown() && v.ValueInt64() < 1 {
+		resp.Diagnostics.AddAttributeError(path.Root("match_count_limit"), "Invalid match count limit", "match_count_limit must be at least 1")
+	}
+}
+
+func (d *MessageSearchDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data MessageSearchDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var parts []string
+	if q := strings.TrimSpace(data.Query.ValueString()); q != "" {
+		parts = append(parts, queryGroup(q))
+	}
+	if !data.Criteria.IsNull() {
+		var criteria FilterCriteriaModel
+		resp.Diagnostics.Append(data.Criteria.As(ctx, &criteria, basetypes.ObjectAsOptions{})...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		q, err := criteriaQuery(convertCriteriaToGmailAPI(ctx, criteria, &resp.Diagnostics))
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("criteria"), "Invalid criteria", err.Error())
+			return
+		}
+		if q != "" {
+			parts = append(parts, q)
+		}
+	}
+	query := strings.Join(parts, " ")
+	if query == "" {
+		resp.Diagnostics.AddError("Invalid message search", "The query and criteria are empty, which would match every message in the mailbox")
+		return
+	}
+
+	sampleSize := defaultMessageSearchSampleSize
+	if !data.SampleSize.IsNull() {
+		sampleSize = int(data.SampleSize.ValueInt64())
+	}
+	countLimit := defaultMessageSearchMatchCountLimit
+	if !data.MatchCountLimit.IsNull() {
+		countLimit = int(data.MatchCountLimit.ValueInt64())
+	}
+
+	// Ask for one more than the limit to tell whether it was reached.
+	ids, err := searchMessageIDs(ctx, d.config.gmailService, query, nil, countLimit+1)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to search messages", err.Error())
+		return
+	}
+	truncated := len(ids) > countLimit
+	if truncated {
+		ids = ids[:countLimit]
+	}
+
+	messages := []MessageSearchMessageModel{}
+	for _, id := range ids[:min(sampleSize, len(ids))] {
+		var m *gmail.Message
+		err := withRetry(ctx, func() error {
+			var err error
+			m, err = d.config.gmailService.Users.Messages.Get(gmailUser, id).Format("metadata").MetadataHeaders("From", "Subject", "Date").Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			if isNotFoundError(err) {
+				continue
+			}
+			resp.Diagnostics.AddError("Failed to read message", err.Error())
+			return
+		}
+		entry := MessageSearchMessageModel{ID: m.Id, ThreadID: m.ThreadId}
+		if m.Payload != nil {
+			for _, h := range m.Payload.Headers {
+				switch strings.ToLower(h.Name) {
+				case "from":
+					entry.From = h.Value
+				case "subject":
+					entry.Subject = h.Value
+				case "date":
+					entry.Date = h.Value
+				}
+			}
+		}
+		messages = append(messages, entry)
+	}
+
+	data.CompiledQuery = types.StringValue(query)
+	data.MatchCount = types.Int64Value(int64(len(ids)))
+	data.MatchCountTruncated = types.BoolValue(truncated)
+	data.Messages = listValueFrom(ctx, messageSearchMessageAttrTypes, messages, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// criteriaObjectEmpty reports whether every attribute of a criteria object is
+// null or set to its zero value, so that it compiles to an empty query.
+// Unknown attributes are assumed not to be empty.
+func criteriaObjectEmpty(criteria types.Object) bool {
+	for _, v := range criteria.Attributes() {
+		if v.IsUnknown() {
+			return false
+		}
+		if v.IsNull() {
+			continue
+		}
+		switch v := v.(type) {
+		case types.String:
+			if strings.TrimSpace(v.ValueString()) != "" {
+				return false
+			}
+		case types.Bool:
+			if v.ValueBool() {
+				return false
+			}
+		case types.Int64:
+			if v.ValueInt64() != 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,369 @@
+package gmailfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/trotttrotttrott/terraform-provider-gmailfilter/simulation"
+	"google.golang.org/api/gmail/v1"
+)
+
+var _ datasource.DataSource = &SimulationDataSource{}
+
+func NewSimulationDataSource() datasource.DataSource {
+	return &SimulationDataSource{}
+}
+
+type SimulationDataSource struct {
+	config *Config
+}
+
+type SimulationDataSourceModel struct {
+	Paths            types.List `tfsdk:"paths"`
+	FilterIDs        types.Set  `tfsdk:"filter_ids"`
+	Filters          types.List `tfsdk:"filters"`
+	SkippedFilterIDs types.List `tfsdk:"skipped_filter_ids"`
+	Messages         types.List `tfsdk:"messages"`
+}
+
+type SimulationFilterModel struct {
+	ID       types.String `tfsdk:"id"`
+	Action   types.Object `tfsdk:"action"`
+	Criteria types.Object `tfsdk:"criteria"`
+}
+
+type SimulationMessageModel struct {
+	Path           string   `tfsdk:"path"`
+	MessageID      string   `tfsdk:"message_id"`
+	From           string   `tfsdk:"from"`
+	Subject        string   `tfsdk:"subject"`
+	FilterIDs      []string `tfsdk:"filter_ids"`
+	AddLabelIDs    []string `tfsdk:"add_label_ids"`
+	RemoveLabelIDs []string `tfsdk:"remove_label_ids"`
+	Forwards       []string `tfsdk:"forwards"`
+}
+
+var simulationMessageAttrTypes = map[string]attr.Type{
+	"path":             types.StringType,
+	"message_id":       types.StringType,
+	"from":             types.StringType,
+	"subject":          types.StringType,
+	"filter_ids":       types.ListType{ElemType: types.StringType},
+	"add_label_ids":    types.ListType{ElemType: types.StringType},
+	"remove_label_ids": types.ListType{ElemType: types.StringType},
+	"forwards":         types.ListType{ElemType: types.StringType},
+}
+
+func (d *SimulationDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_simulation"
+}
+
+func (d *SimulationDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Evaluates Gmail filters against .eml files without touching the mailbox, and returns the label changes each message would get. " +
+			"Matching is an approximation of Gmail's: queries may use free text, phrases, OR, -, grouping and the from, to, cc, bcc, subject, list, deliveredto, filename, has:attachment, larger, smaller and size operators. " +
+			"Account filters using other operators are skipped with a warning, while proposed filters using them are an error.",
+		Attributes: map[string]schema.Attribute{
+			"paths": schema.ListAttribute{
+				ElementType: types.StringType,
+				Required:    true,
+				Description: "Paths of .eml files, or of directories whose .eml files are all read",
+			},
+			"filter_ids": schema.SetAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "The IDs of the account's filters to simulate. Defaults to all filters; set to an empty set to only simulate the filters given in filters.",
+			},
+			"filters": proposedFiltersAttribute("Filters that do not exist yet, simulated in addition to the account's filters"),
+			"skipped_filter_ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Description: "The IDs of the filters that could not be simulated because their queries use unsupported operators",
+			},
+			"messages": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The messages, ordered by path, with the effect of the matching filters",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"path": schema.StringAttribute{
+							Computed:    true,
+							Description: "The path of the .eml file",
+						},
+						"message_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The Message-ID header of the message",
+						},
+						"from": schema.StringAttribute{
+							Computed:    true,
+							Description: "The sender of the message",
+						},
+						"subject": schema.StringAttribute{
+							Computed:    true,
+							Description: "The subject of the message",
+						},
+						"filter_ids": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "The IDs of the filters matching the message",
+						},
+						"add_label_ids": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "The labels the matching filters add",
+						},
+						"remove_label_ids": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "The labels the matching filters remove",
+						},
+						"forwards": schema.ListAttribute{
+							ElementType: types.StringType,
+							Computed:    true,
+							Description: "The addresses the message would be forwarded to",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *SimulationDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Data Source Configure Type", "Expected *Config")
+		return
+	}
+	d.config = config
+}
+
+func (d *SimulationDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data SimulationDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var paths []string
+	resp.Diagnostics.Append(data.Paths.ElementsAs(ctx, &paths, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	files, err := emlFiles(paths)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("paths"), "Failed to read messages", err.Error())
+		return
+	}
+	messages := make([]*simulation.Message, 0, len(files))
+	for _, file := range files {
+		m, err := simulation.ParseFile(file)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("paths"), "Failed to parse message", err.Error())
+			return
+		}
+		messages = append(messages, m)
+	}
+
+	filters := d.accountFilters(ctx, data, resp)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	proposed := proposedFilters(ctx, data.Filters, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Account filters Gmail accepted may still use operators the simulation
+	// does not know, which only skips them. Anything else is a bug in the
+	// criteria or the simulation and fails.
+	compiled := make([]*simulation.Filter, 0, len(filters)+len(proposed))
+	skipped := []string{}
+	for _, f := range filters {
+		c, err := simulation.CompileFilter(f)
+		if err != nil {
+			if errors.As(err, new(*simulation.UnsupportedError)) {
+				resp.Diagnostics.AddWarning("Filter not simulated", err.Error())
+				skipped = append(skipped, f.Id)
+			} else {
+				resp.Diagnostics.AddError("Failed to compile filter", err.Error())
+			}
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+	for i, f := range proposed {
+		c, err := simulation.CompileFilter(f)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("filters").AtListIndex(i), "Failed to compile filter", err.Error())
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	results := []SimulationMessageModel{}
+	for _, m := range messages {
+		r := simulation.Simulate(compiled, m)
+		results = append(results, SimulationMessageModel{
+			Path:           m.ID,
+			MessageID:      m.MessageID,
+			From:           m.From,
+			Subject:        m.Subject,
+			FilterIDs:      r.FilterIDs,
+			AddLabelIDs:    r.AddLabelIDs,
+			RemoveLabelIDs: r.RemoveLabelIDs,
+			Forwards:       r.Forwards,
+		})
+	}
+
+	var diags diag.Diagnostics
+	data.SkippedFilterIDs, diags = types.ListValueFrom(ctx, types.StringType, skipped)
+	resp.Diagnostics.Append(diags...)
+	data.Messages = listValueFrom(ctx, simulationMessageAttrTypes, results, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// accountFilters returns the account's filters selected by filter_ids.
+func (d *SimulationDataSource) accountFilters(ctx context.Context, data SimulationDataSourceModel, resp *datasource.ReadResponse) []*gmail.Filter {
+	var ids []string
+	if !data.FilterIDs.IsNull() {
+		resp.Diagnostics.Append(data.FilterIDs.ElementsAs(ctx, &ids, false)...)
+		if resp.Diagnostics.HasError() || len(ids) == 0 {
+			return nil
+		}
+	}
+
+	filters, err := listFilters(d.config.gmailService)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list filters", err.Error())
+		return nil
+	}
+	if ids == nil {
+		return filters
+	}
+
+	byID := map[string]*gmail.Filter{}
+	for _, f := range filters {
+		byID[f.Id] = f
+	}
+	var selected []*gmail.Filter
+	for _, id := range ids {
+		f, ok := byID[id]
+		if !ok {
+			resp.Diagnostics.AddAttributeError(path.Root("filter_ids"), "Filter not found",
+				fmt.Sprintf("No filter with ID %q found", id))
+			continue
+		}
+		selected = append(selected, f)
+	}
+	return selected
+}
+
+// emlFiles expands directories in paths to the .eml files they contain and
+// returns all files sorted.
+func emlFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".eml") {
+				files = append(files, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// proposedFiltersAttribute describes filters that do not exist yet, given to
+// data sources that evaluate them along with the account's filters.
+func proposedFiltersAttribute(description string) schema.ListNestedAttribute {
+	return schema.ListNestedAttribute{
+		Optional:    true,
+		Description: description,
+		NestedObject: schema.NestedAttributeObject{
+			Attributes: map[string]schema.Attribute{
+				"id": schema.StringAttribute{
+					Optional:    true,
+					Description: "The name of the filter in results. Defaults to proposed-N, where N is the position in the list starting at 1.",
+				},
+				"action": schema.SingleNestedAttribute{
+					Required:    true,
+					Description: "Action that the filter performs",
+					Attributes:  filterActionDataSourceAttributes(true, false),
+				},
+				"criteria": schema.SingleNestedAttribute{
+					Required:    true,
+					Description: "The criteria that a message should match to apply the filter",
+					Attributes:  filterCriteriaDataSourceAttributes(true, false),
+				},
+			},
+		},
+	}
+}
+
+// proposedFilters converts a list described by proposedFiltersAttribute into
+// Gmail filters, named by their id or by their position.
+func proposedFilters(ctx context.Context, list types.List, diags *diag.Diagnostics) []*gmail.Filter {
+	if list.IsNull() {
+		return nil
+	}
+	var proposed []SimulationFilterModel
+	diags.Append(list.ElementsAs(ctx, &proposed, false)...)
+	if diags.HasError() {
+		return nil
+	}
+
+	var filters []*gmail.Filter
+	for i, p := range proposed {
+		var action FilterActionModel
+		var criteria FilterCriteriaModel
+		diags.Append(p.Action.As(ctx, &action, basetypes.ObjectAsOptions{})...)
+		diags.Append(p.Criteria.As(ctx, &criteria, basetypes.ObjectAsOptions{})...)
+		if diags.HasError() {
+			return nil
+		}
+		id := p.ID.ValueString()
+		if id == "" {
+			id = fmt.Sprintf("proposed-%d", i+1)
+		}
+		filters = append(filters, &gmail.Filter{
+			Id:       id,
+			Action:   convertActionToGmailAPI(ctx, action, diags),
+			Criteria: convertCriteriaToGmailAPI(ctx, criteria, diags),
+		})
+	}
+	return filters
+}
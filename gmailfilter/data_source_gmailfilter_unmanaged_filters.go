@@ -0,0 +1,111 @@
+package gmailfilter
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/gmail/v1"
+)
+
+var _ datasource.DataSource = &UnmanagedFiltersDataSource{}
+
+func NewUnmanagedFiltersDataSource() datasource.DataSource {
+	return &UnmanagedFiltersDataSource{}
+}
+
+type UnmanagedFiltersDataSource struct {
+	config *Config
+}
+
+type UnmanagedFiltersDataSourceModel struct {
+	ManagedIDs  types.Set   `tfsdk:"managed_ids"`
+	IDs         types.List  `tfsdk:"ids"`
+	FilterCount types.Int64 `tfsdk:"filter_count"`
+	Filters     types.List  `tfsdk:"filters"`
+}
+
+func (d *UnmanagedFiltersDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_unmanaged_filters"
+}
+
+func (d *UnmanagedFiltersDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Lists the Gmail filters that are not in a given set of managed filter IDs, e.g. to detect filters created by hand",
+		Attributes: map[string]schema.Attribute{
+			"managed_ids": schema.SetAttribute{
+				ElementType: types.StringType,
+				Required:    true,
+				Description: "The IDs of the managed filters, usually gmailfilter_filter.*.id",
+			},
+			"ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Description: "The IDs of the unmanaged filters",
+			},
+			"filter_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of unmanaged filters",
+			},
+			"filters": schema.ListNestedAttribute{
+				Computed:     true,
+				Description:  "The unmanaged filters",
+				NestedObject: filterDataSourceNestedObject(),
+			},
+		},
+	}
+}
+
+func (d *UnmanagedFiltersDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Data Source Configure Type", "Expected *Config")
+		return
+	}
+	d.config = config
+}
+
+func (d *UnmanagedFiltersDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data UnmanagedFiltersDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var managedIDs []string
+	resp.Diagnostics.Append(data.ManagedIDs.ElementsAs(ctx, &managedIDs, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	managed := make(map[string]bool, len(managedIDs))
+	for _, id := range managedIDs {
+		managed[id] = true
+	}
+
+	filters, err := listFilters(d.config.gmailService)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list filters", err.Error())
+		return
+	}
+
+	var unmanaged []*gmail.Filter
+	for _, f := range filters {
+		if !managed[f.Id] {
+			unmanaged = append(unmanaged, f)
+		}
+	}
+
+	ids, list := flattenFilterDataSourceList(ctx, unmanaged, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.IDs = ids
+	data.FilterCount = types.Int64Value(int64(len(unmanaged)))
+	data.Filters = list
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,228 @@
+package gmailfilter
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Gmail matches filters on message content, which is not available here, so
+// the comparisons below only decide what follows from the criteria alone. They
+// err on the side of "unknown": criteriaSubsumes and criteriaDisjoint only
+// return true when that holds for every possible message.
+
+// criteriaSubsumes reports whether every message matching specific also
+// matches general.
+func criteriaSubsumes(general, specific *gmail.FilterCriteria) bool {
+	g, s := normalizeCriteria(general), normalizeCriteria(specific)
+
+	return fieldImplies(s.From, g.From) &&
+		fieldImplies(s.To, g.To) &&
+		fieldImplies(s.Subject, g.Subject) &&
+		queryImplies(s.Query, g.Query) &&
+		// A message must not match the negated query, so a shorter negated
+		// query excludes more messages.
+		queryImplies(g.NegatedQuery, s.NegatedQuery) &&
+		(!g.HasAttachment || s.HasAttachment) &&
+		(!g.ExcludeChats || s.ExcludeChats) &&
+		sizeImplies(s, g)
+}
+
+// criteriaEquivalent reports whether a and b match the same messages.
+func criteriaEquivalent(a, b *gmail.FilterCriteria) bool {
+	return criteriaSubsumes(a, b) && criteriaSubsumes(b, a)
+}
+
+// criteriaDisjoint reports whether no message can match both a and b.
+func criteriaDisjoint(a, b *gmail.FilterCriteria) bool {
+	x, y := normalizeCriteria(a), normalizeCriteria(b)
+	if x.Size == 0 || y.Size == 0 {
+		return false
+	}
+	switch {
+	case x.SizeComparison == "larger" && y.SizeComparison == "smaller":
+		return y.Size <= x.Size+1
+	case x.SizeComparison == "smaller" && y.SizeComparison == "larger":
+		return x.Size <= y.Size+1
+	}
+	return false
+}
+
+func normalizeCriteria(c *gmail.FilterCriteria) gmail.FilterCriteria {
+	if c == nil {
+		return gmail.FilterCriteria{}
+	}
+	n := *c
+	n.From = strings.ToLower(strings.TrimSpace(n.From))
+	n.To = strings.ToLower(strings.TrimSpace(n.To))
+	n.Subject = strings.ToLower(strings.TrimSpace(n.Subject))
+	n.Query = strings.ToLower(strings.TrimSpace(n.Query))
+	n.NegatedQuery = strings.ToLower(strings.TrimSpace(n.NegatedQuery))
+	if n.Size == 0 {
+		n.SizeComparison = ""
+	}
+	return n
+}
+
+// fieldImplies reports whether a from, to or subject field with value s
+// implies one with value want, both normalized. Gmail matches plain values as
+// substrings, so a longer value that contains a shorter one is more specific.
+// Values using search syntax such as OR, braces or negation are only compared
+// verbatim.
+func fieldImplies(s, want string) bool {
+	if want == "" || s == want {
+		return true
+	}
+	if s == "" || !isPlainQuery(s) || !isPlainQuery(want) {
+		return false
+	}
+	return strings.Contains(s, want)
+}
+
+// queryImplies reports whether a message matching query a is known to match
+// query b, both normalized. Queries made of plain terms are implicitly ANDed,
+// so a implies b when it has all of b's terms. Queries using operators such
+// as OR, grouping or negation are only compared verbatim.
+func queryImplies(a, b string) bool {
+	if b == "" || a == b {
+		return true
+	}
+	if a == "" || !isPlainQuery(a) || !isPlainQuery(b) {
+		return false
+	}
+	terms := map[string]bool{}
+	for _, t := range strings.Fields(a) {
+		terms[t] = true
+	}
+	for _, t := range strings.Fields(b) {
+		if !terms[t] {
+			return false
+		}
+	}
+	return true
+}
+
+func isPlainQuery(q string) bool {
+	for _, t := range strings.Fields(q) {
+		if t == "or" || t == "and" || strings.ContainsAny(t, `(){}"|`) || strings.HasPrefix(t, "-") {
+			return false
+		}
+	}
+	return true
+}
+
+// sizeImplies reports whether the size criteria of s imply those of g, both
+// normalized.
+func sizeImplies(s, g gmail.FilterCriteria) bool {
+	if g.Size == 0 {
+		return true
+	}
+	if s.Size == 0 || s.SizeComparison != g.SizeComparison {
+		return false
+	}
+	switch g.SizeComparison {
+	case "larger":
+		return s.Size >= g.Size
+	case "smaller":
+		return s.Size <= g.Size
+	}
+	return s.Size == g.Size
+}
+
+// filterActionKey returns a key that is equal for two filters with the same
+// action, regardless of label ordering.
+func filterActionKey(f *gmail.Filter) string {
+	return filterFingerprint(&gmail.Filter{Action: f.Action})
+}
+
+// criteriaQuery compiles filter criteria into the Gmail search query that
+// matches the same messages, for use with Messages.List. Every value is
+// grouped, so it fails for values with unbalanced brackets or quotes, which
+// would change the meaning of the rest of the query. Like Messages.List, the
+// query does not cover spam and trash.
+func criteriaQuery(c *gmail.FilterCriteria) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+	for _, v := range []struct{ name, value string }{
+		{"from", c.From}, {"to", c.To}, {"subject", c.Subject},
+		{"query", c.Query}, {"negated_query", c.NegatedQuery},
+	} {
+		if err := checkQueryBalanced(v.value); err != nil {
+			return "", fmt.Errorf("criteria %s %q: %w", v.name, v.value, err)
+		}
+	}
+
+	var parts []string
+	field := func(op, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			parts = append(parts, op+":"+queryGroup(value))
+		}
+	}
+	field("from", c.From)
+	field("to", c.To)
+	field("subject", c.Subject)
+	if q := strings.TrimSpace(c.Query); q != "" {
+		parts = append(parts, queryGroup(q))
+	}
+	if q := strings.TrimSpace(c.NegatedQuery); q != "" {
+		parts = append(parts, "-"+queryGroup(q))
+	}
+	if c.HasAttachment {
+		parts = append(parts, "has:attachment")
+	}
+	if c.ExcludeChats {
+		parts = append(parts, "-in:chats")
+	}
+	if c.Size != 0 {
+		switch c.SizeComparison {
+		case "larger":
+			parts = append(parts, fmt.Sprintf("larger:%d", c.Size))
+		case "smaller":
+			parts = append(parts, fmt.Sprintf("smaller:%d", c.Size))
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// checkQueryBalanced returns an error if value has unbalanced brackets or
+// an unterminated quote. Brackets inside quotes are not counted.
+func checkQueryBalanced(value string) error {
+	var stack []rune
+	quoted := false
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '(' || r == '{':
+			stack = append(stack, r)
+		case r == ')' || r == '}':
+			open := '('
+			if r == '}' {
+				open = '{'
+			}
+			if len(stack) == 0 || stack[len(stack)-1] != open {
+				return fmt.Errorf("unbalanced %q", r)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if quoted {
+		return fmt.Errorf("unterminated quote")
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("unbalanced %q", stack[len(stack)-1])
+	}
+	return nil
+}
+
+// queryGroup wraps a query value in brackets unless it is a single term, so it
+// keeps its meaning when combined with other terms.
+func queryGroup(value string) string {
+	if !strings.ContainsAny(value, " \t(){}\"|") {
+		return value
+	}
+	return "(" + value + ")"
+}
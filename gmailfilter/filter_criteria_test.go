@@ -0,0 +1,137 @@
+package gmailfilter
+
+import (
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestCriteriaSubsumes(t *testing.T) {
+	tests := []struct {
+		name              string
+		general, specific *gmail.FilterCriteria
+		want              bool
+	}{
+		{"empty general", &gmail.FilterCriteria{}, &gmail.FilterCriteria{From: "a@x.com"}, true},
+		{"nil general", nil, &gmail.FilterCriteria{From: "a@x.com"}, true},
+		{"same from", &gmail.FilterCriteria{From: "a@x.com"}, &gmail.FilterCriteria{From: "A@X.com "}, true},
+		{"longer from", &gmail.FilterCriteria{From: "x.com"}, &gmail.FilterCriteria{From: "a@x.com"}, true},
+		{"shorter from", &gmail.FilterCriteria{From: "a@x.com"}, &gmail.FilterCriteria{From: "x.com"}, false},
+		{"from missing", &gmail.FilterCriteria{From: "x.com"}, &gmail.FilterCriteria{To: "x.com"}, false},
+		{"from with OR", &gmail.FilterCriteria{From: "a@x.com OR b@y.com"}, &gmail.FilterCriteria{From: "a@x.com"}, false},
+		{"specific from with OR", &gmail.FilterCriteria{From: "a@x.com"}, &gmail.FilterCriteria{From: "a@x.com OR b@y.com"}, false},
+		{"from with braces", &gmail.FilterCriteria{From: "x.com"}, &gmail.FilterCriteria{From: "{a@x.com b@x.com}"}, false},
+		{"from with negation", &gmail.FilterCriteria{From: "x.com"}, &gmail.FilterCriteria{From: "x.com -a@x.com"}, false},
+		{"subject with parentheses", &gmail.FilterCriteria{Subject: "invoice"}, &gmail.FilterCriteria{Subject: "(invoice receipt)"}, false},
+		{"identical syntax", &gmail.FilterCriteria{From: "a@x.com OR b@y.com"}, &gmail.FilterCriteria{From: "a@x.com or b@y.com"}, true},
+		{"query terms", &gmail.FilterCriteria{Query: "invoice"}, &gmail.FilterCriteria{Query: "invoice paid"}, true},
+		{"query missing term", &gmail.FilterCriteria{Query: "invoice paid"}, &gmail.FilterCriteria{Query: "invoice"}, false},
+		{"negated query", &gmail.FilterCriteria{NegatedQuery: "spam offer"}, &gmail.FilterCriteria{NegatedQuery: "spam"}, true},
+		{"negated query reversed", &gmail.FilterCriteria{NegatedQuery: "spam"}, &gmail.FilterCriteria{NegatedQuery: "spam offer"}, false},
+		{"attachment", &gmail.FilterCriteria{HasAttachment: true}, &gmail.FilterCriteria{HasAttachment: true, From: "a"}, true},
+		{"attachment missing", &gmail.FilterCriteria{HasAttachment: true}, &gmail.FilterCriteria{From: "a"}, false},
+		{"larger", &gmail.FilterCriteria{Size: 100, SizeComparison: "larger"}, &gmail.FilterCriteria{Size: 200, SizeComparison: "larger"}, true},
+		{"larger reversed", &gmail.FilterCriteria{Size: 200, SizeComparison: "larger"}, &gmail.FilterCriteria{Size: 100, SizeComparison: "larger"}, false},
+		{"smaller", &gmail.FilterCriteria{Size: 200, SizeComparison: "smaller"}, &gmail.FilterCriteria{Size: 100, SizeComparison: "smaller"}, true},
+		{"size comparison differs", &gmail.FilterCriteria{Size: 100, SizeComparison: "larger"}, &gmail.FilterCriteria{Size: 100, SizeComparison: "smaller"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := criteriaSubsumes(tt.general, tt.specific); got != tt.want {
+				t.Errorf("criteriaSubsumes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCriteriaEquivalent(t *testing.T) {
+	a := &gmail.FilterCriteria{From: "a@x.com", Query: "paid invoice"}
+	b := &gmail.FilterCriteria{From: "A@x.com", Query: "invoice paid"}
+	if !criteriaEquivalent(a, b) {
+		t.Errorf("criteriaEquivalent(%v, %v) = false, want true", a, b)
+	}
+	c := &gmail.FilterCriteria{From: "a@x.com OR b@y.com"}
+	d := &gmail.FilterCriteria{From: "a@x.com"}
+	if criteriaEquivalent(c, d) {
+		t.Errorf("criteriaEquivalent(%v, %v) = true, want false", c, d)
+	}
+}
+
+func TestQueryImplies(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"foo", "", true},
+		{"", "foo", false},
+		{"foo bar", "bar", true},
+		{"foo", "foo bar", false},
+		{"foo or bar", "foo or bar", true},
+		{"foo or bar", "foo", false},
+		{"foo", "foo or bar", false},
+		{"(foo bar)", "foo", false},
+		{"-foo bar", "bar", false},
+		{`"foo bar" baz`, "baz", false},
+		{"{foo bar}", "foo", false},
+	}
+	for _, tt := range tests {
+		if got := queryImplies(tt.a, tt.b); got != tt.want {
+			t.Errorf("queryImplies(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCriteriaQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		criteria *gmail.FilterCriteria
+		want     string
+	}{
+		{"nil", nil, ""},
+		{"empty", &gmail.FilterCriteria{}, ""},
+		{"from", &gmail.FilterCriteria{From: "a@x.com"}, "from:a@x.com"},
+		{"from with OR", &gmail.FilterCriteria{From: "a@x.com OR b@y.com"}, "from:(a@x.com OR b@y.com)"},
+		{"subject with spaces", &gmail.FilterCriteria{Subject: "weekly report"}, "subject:(weekly report)"},
+		{"query and negated query", &gmail.FilterCriteria{Query: "invoice", NegatedQuery: "spam offer"}, "invoice -(spam offer)"},
+		{
+			"all fields",
+			&gmail.FilterCriteria{
+				From: "a", To: "b", Subject: "c", Query: "d", NegatedQuery: "e",
+				HasAttachment: true, ExcludeChats: true, Size: 1024, SizeComparison: "larger",
+			},
+			"from:a to:b subject:c d -e has:attachment -in:chats larger:1024",
+		},
+		{"smaller", &gmail.FilterCriteria{Size: 10, SizeComparison: "smaller"}, "smaller:10"},
+		{"size without comparison", &gmail.FilterCriteria{Size: 10}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := criteriaQuery(tt.criteria)
+			if err != nil {
+				t.Fatalf("criteriaQuery(): %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("criteriaQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCriteriaQueryErrors(t *testing.T) {
+	for _, c := range []*gmail.FilterCriteria{
+		{From: "a@x.com)"},
+		{From: "(a@x.com"},
+		{To: "{a b)"},
+		{Subject: `"unterminated`},
+		{Query: "foo) OR (bar"},
+		{NegatedQuery: "}"},
+	} {
+		if q, err := criteriaQuery(c); err == nil {
+			t.Errorf("criteriaQuery(%+v) = %q, want an error", c, q)
+		}
+	}
+	if _, err := criteriaQuery(&gmail.FilterCriteria{Subject: `"a (b" {c d}`}); err != nil {
+		t.Errorf("criteriaQuery() with balanced brackets: %v", err)
+	}
+}
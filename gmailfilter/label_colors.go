@@ -0,0 +1,114 @@
+package gmailfilter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// labelColorHues are the columns of the color grid in the Gmail web
+// interface, in display order.
+var labelColorHues = []string{"red", "orange", "yellow", "green", "teal", "blue", "purple", "pink"}
+
+// labelColorShades are the rows of the color grid in the Gmail web interface
+// below the grays. The empty suffix is the base color of a hue.
+var labelColorShades = []struct {
+	suffix string
+	colors []string
+}{
+	{"", []string{"#fb4c2f", "#ffad47", "#fad165", "#16a766", "#43d692", "#4a86e8", "#a479e2", "#f691b3"}},
+	{"-lightest", []string{"#f6c5be", "#ffe6c7", "#fef1d1", "#b9e4d0", "#c6f3de", "#c9daf8", "#e4d7f5", "#fcdee8"}},
+	{"-lighter", []string{"#efa093", "#ffd6a2", "#fce8b3", "#89d3b2", "#a0eac9", "#a4c2f4", "#d0bcf1", "#fbc8d9"}},
+	{"-light", []string{"#e66550", "#ffbc6b", "#fcda83", "#44b984", "#68dfa9", "#6d9eeb", "#b694e8", "#f7a7c0"}},
+	{"-dark", []string{"#cc3a21", "#eaa041", "#f2c960", "#149e60", "#3dc789", "#3c78d8", "#8e63ce", "#e07798"}},
+	{"-darker", []string{"#ac2b16", "#cf8933", "#d5ae49", "#0b804b", "#2a9c68", "#285bac", "#653e9b", "#b65775"}},
+	{"-darkest", []string{"#822111", "#a46a21", "#aa8831", "#076239", "#1a764d", "#1c4587", "#41236d", "#83334c"}},
+}
+
+// labelColorGrays is the top row of the color grid.
+var labelColorGrays = map[string]string{
+	"black":         "#000000",
+	"gray-darkest":  "#434343",
+	"gray-darker":   "#666666",
+	"gray":          "#999999",
+	"gray-light":    "#cccccc",
+	"gray-lighter":  "#efefef",
+	"gray-lightest": "#f3f3f3",
+	"white":         "#ffffff",
+}
+
+// labelColorLegacy are the remaining colors Gmail accepts, which are not
+// shown in the color grid and have no names.
+var labelColorLegacy = []string{
+	"#464646", "#e7e7e7", "#0d3472", "#b6cff5", "#0d3b44", "#98d7e4", "#3d188e", "#e3d7ff",
+	"#711a36", "#fbd3e0", "#8a1c0a", "#f2b2a8", "#7a2e0b", "#ffc8af", "#7a4706", "#ffdeb5",
+	"#594c05", "#fbe983", "#684e07", "#fdedc1", "#0b4f30", "#b3efd3", "#04502e", "#a2dcc1",
+	"#c2c2c2", "#4986e7", "#2da2bb", "#b99aff", "#994a64", "#f691b2", "#ff7537", "#ffad46",
+	"#662e37", "#ebdbde", "#cca6ac", "#094228", "#42d692", "#16a765",
+}
+
+var (
+	labelColorNames   = map[string]string{}
+	labelColorPalette = map[string]bool{}
+	// labelColorAuto lists the colors that may be picked for a label
+	// automatically, in a fixed order so the choice is stable.
+	labelColorAuto []string
+)
+
+func init() {
+	for name, hex := range labelColorGrays {
+		labelColorNames[name] = hex
+		labelColorPalette[hex] = true
+	}
+	for _, shade := range labelColorShades {
+		for i, hex := range shade.colors {
+			labelColorNames[labelColorHues[i]+shade.suffix] = hex
+			labelColorPalette[hex] = true
+			labelColorAuto = append(labelColorAuto, hex)
+		}
+	}
+	for _, hex := range labelColorLegacy {
+		labelColorPalette[hex] = true
+	}
+}
+
+// resolveLabelColor turns a palette name or hex string into the lowercase hex
+// string Gmail expects.
+func resolveLabelColor(value string) (string, error) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	if hex, ok := labelColorNames[v]; ok {
+		return hex, nil
+	}
+	if labelColorPalette[v] {
+		return v, nil
+	}
+	return "", fmt.Errorf("%q is neither a Gmail palette color nor a palette name such as \"blue-light\"", value)
+}
+
+// autoLabelColor picks a background color from the palette based on a hash of
+// name, so a label always gets the same color.
+func autoLabelColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return labelColorAuto[h.Sum32()%uint32(len(labelColorAuto))]
+}
+
+// contrastingLabelTextColor returns black or white, whichever is easier to
+// read on top of the background color hex.
+func contrastingLabelTextColor(hex string) string {
+	rgb, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return "#000000"
+	}
+	r, g, b := float64(rgb>>16&0xff), float64(rgb>>8&0xff), float64(rgb&0xff)
+	if 0.299*r+0.587*g+0.114*b > 150 {
+		return "#000000"
+	}
+	return "#ffffff"
+}
+
+// labelColorsEqual compares two hex colors the way Gmail does, ignoring case.
+func labelColorsEqual(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
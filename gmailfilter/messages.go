@@ -0,0 +1,121 @@
+package gmailfilter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// batchModifyLimit is the maximum number of message IDs Gmail accepts in
+	// a single BatchModify request.
+	batchModifyLimit = 1000
+
+	maxRetries = 5
+)
+
+// searchMessageIDs returns the IDs of the messages matching query that carry
+// all of labelIDs. Either may be empty. When limit is greater than zero, at
+// most limit IDs are returned.
+func searchMessageIDs(ctx context.Context, svc *gmail.Service, query string, labelIDs []string, limit int) ([]string, error) {
+	var ids []string
+	pageToken := ""
+	for {
+		call := svc.Users.Messages.List(gmailUser).Context(ctx).MaxResults(500)
+		if query != "" {
+			call = call.Q(query)
+		}
+		if len(labelIDs) > 0 {
+			call = call.LabelIds(labelIDs...)
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		var res *gmail.ListMessagesResponse
+		err := withRetry(ctx, func() error {
+			var err error
+			res, err = call.Do()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range res.Messages {
+			ids = append(ids, m.Id)
+			if limit > 0 && len(ids) >= limit {
+				return ids, nil
+			}
+		}
+		if res.NextPageToken == "" {
+			return ids, nil
+		}
+		pageToken = res.NextPageToken
+	}
+}
+
+// batchModifyMessages adds and removes labels on the given messages, split
+// into as many requests as Gmail requires. progress, if not nil, is called
+// with the number of messages modified so far after each request.
+func batchModifyMessages(ctx context.Context, svc *gmail.Service, ids, addLabelIDs, removeLabelIDs []string, progress func(done int)) error {
+	for start := 0; start < len(ids); start += batchModifyLimit {
+		end := min(start+batchModifyLimit, len(ids))
+		req := &gmail.BatchModifyMessagesRequest{
+			Ids:            ids[start:end],
+			AddLabelIds:    addLabelIDs,
+			RemoveLabelIds: removeLabelIDs,
+		}
+		err := withRetry(ctx, func() error {
+			return svc.Users.Messages.BatchModify(gmailUser, req).Context(ctx).Do()
+		})
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(end)
+		}
+	}
+	return nil
+}
+
+// withRetry calls fn until it succeeds, returns an error that is not worth
+// retrying, or maxRetries attempts have been made. Attempts are spaced out
+// with exponential backoff.
+func withRetry(ctx context.Context, fn func() error) error {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == maxRetries || !isRetryableError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func isRetryableError(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusForbidden:
+		for _, e := range apiErr.Errors {
+			if e.Reason == "rateLimitExceeded" || e.Reason == "userRateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	return false
+}
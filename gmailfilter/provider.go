@@ -3,6 +3,7 @@ package gmailfilter
 import (
 	"context"
 
+	"github.com/hashicorp/terraform-plugin-framework/action"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
@@ -10,6 +11,7 @@ import (
 )
 
 var _ provider.Provider = &GmailFilterProvider{}
+var _ provider.ProviderWithActions = &GmailFilterProvider{}
 
 type GmailFilterProvider struct {
 	version string
@@ -42,18 +44,36 @@ func (p *GmailFilterProvider) Configure(ctx context.Context, req provider.Config
 	}
 	resp.ResourceData = config
 	resp.DataSourceData = config
+	resp.ActionData = config
 }
 
 func (p *GmailFilterProvider) Resources(ctx context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewFilterResource,
+		NewFilterSetResource,
 		NewLabelResource,
+		NewLabelRetentionResource,
+		NewLabelsResource,
+		NewSystemLabelResource,
 	}
 }
 
 func (p *GmailFilterProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
 		NewFilterDataSource,
+		NewFilterAnalysisDataSource,
+		NewFiltersDataSource,
 		NewLabelDataSource,
+		NewLabelStatsDataSource,
+		NewLabelsDataSource,
+		NewMessageSearchDataSource,
+		NewSimulationDataSource,
+		NewUnmanagedFiltersDataSource,
+	}
+}
+
+func (p *GmailFilterProvider) Actions(ctx context.Context) []func() action.Action {
+	return []func() action.Action{
+		NewBatchModifyAction,
 	}
 }
@@ -2,24 +2,31 @@ package gmailfilter
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/objectplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"google.golang.org/api/gmail/v1"
 )
 
 var _ resource.Resource = &FilterResource{}
 var _ resource.ResourceWithImportState = &FilterResource{}
 var _ resource.ResourceWithUpgradeState = &FilterResource{}
+var _ resource.ResourceWithModifyPlan = &FilterResource{}
 
 func NewFilterResource() resource.Resource {
 	return &FilterResource{}
@@ -30,9 +37,12 @@ type FilterResource struct {
 }
 
 type FilterResourceModel struct {
-	ID       types.String `tfsdk:"id"`
-	Action   types.Object `tfsdk:"action"`
-	Criteria types.Object `tfsdk:"criteria"`
+	ID                   types.String `tfsdk:"id"`
+	DeletionPolicy       types.String `tfsdk:"deletion_policy"`
+	ApplyToExisting      types.Bool   `tfsdk:"apply_to_existing"`
+	ApplyToExistingLimit types.Int64  `tfsdk:"apply_to_existing_limit"`
+	Action               types.Object `tfsdk:"action"`
+	Criteria             types.Object `tfsdk:"criteria"`
 }
 
 type FilterActionModel struct {
@@ -69,6 +79,20 @@ func (r *FilterResource) Schema(ctx context.Context, req resource.SchemaRequest,
 					stringplanmodifier.UseStateForUnknown(),
 				},
 			},
+			"deletion_policy": deletionPolicyAttribute("filter"),
+			"apply_to_existing": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether to also add and remove the action's labels on existing messages matching the criteria when the filter is created, like \"Also apply filter to matching conversations\" in Gmail. Unlike Gmail, messages in spam and trash are not included, and messages are not forwarded. This only happens on creation: setting it on a filter that already exists, or unsetting it, changes nothing in the mailbox.",
+			},
+			"apply_to_existing_limit": schema.Int64Attribute{
+				Optional:    true,
+				Description: "The maximum number of existing messages apply_to_existing modifies. Unlimited when not set.",
+				Validators: []validator.Int64{
+					int64AtLeast(1),
+				},
+			},
 		},
 		Blocks: map[string]schema.Block{
 			"action": schema.SingleNestedBlock{
@@ -76,66 +100,14 @@ func (r *FilterResource) Schema(ctx context.Context, req resource.SchemaRequest,
 				PlanModifiers: []planmodifier.Object{
 					objectplanmodifier.RequiresReplace(),
 				},
-				Attributes: map[string]schema.Attribute{
-					"add_label_ids": schema.ListAttribute{
-						ElementType: types.StringType,
-						Optional:    true,
-						Description: "List of labels to add to the message",
-					},
-					"forward": schema.StringAttribute{
-						Optional:    true,
-						Description: "Email address that the message should be forwarded to",
-					},
-					"remove_label_ids": schema.ListAttribute{
-						ElementType: types.StringType,
-						Optional:    true,
-						Description: "List of labels to remove from the message",
-					},
-				},
+				Attributes: filterActionAttributes(),
 			},
 			"criteria": schema.SingleNestedBlock{
 				Description: "The criteria that a message should match to apply the filter. Changes to this block will require the filter to be recreated.",
 				PlanModifiers: []planmodifier.Object{
 					objectplanmodifier.RequiresReplace(),
 				},
-				Attributes: map[string]schema.Attribute{
-					"exclude_chats": schema.BoolAttribute{
-						Optional:    true,
-						Description: "Whether the response should exclude chats",
-					},
-					"from": schema.StringAttribute{
-						Optional:    true,
-						Description: "The sender's display name or email address",
-					},
-					"has_attachment": schema.BoolAttribute{
-						Optional:    true,
-						Description: "Whether the message has any attachment",
-					},
-					"negated_query": schema.StringAttribute{
-						Optional:    true,
-						Description: "Only return messages not matching the specified query",
-					},
-					"query": schema.StringAttribute{
-						Optional:    true,
-						Description: "Only return messages matching the specified query",
-					},
-					"size": schema.Int64Attribute{
-						Optional:    true,
-						Description: "The size of the entire RFC822 message in bytes",
-					},
-					"size_comparison": schema.StringAttribute{
-						Optional:    true,
-						Description: "How the message size should be compared (larger/smaller/unspecified)",
-					},
-					"subject": schema.StringAttribute{
-						Optional:    true,
-						Description: "Case-insensitive phrase found in the message's subject",
-					},
-					"to": schema.StringAttribute{
-						Optional:    true,
-						Description: "The recipient's display name or email address",
-					},
-				},
+				Attributes: filterCriteriaAttributes(),
 			},
 		},
 	}
@@ -179,6 +151,17 @@ func (r *FilterResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
+	// Check the search before creating the filter, so that a filter whose
+	// messages cannot be found is not left behind.
+	var query string
+	if data.ApplyToExisting.ValueBool() && actionChangesLabels(filter.Action) {
+		var err error
+		if query, err = existingMessagesQuery(filter.Criteria); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("criteria"), "Cannot apply filter to existing messages", err.Error())
+			return
+		}
+	}
+
 	result, err := r.config.gmailService.Users.Settings.Filters.Create(gmailUser, filter).Do()
 	if err != nil {
 		resp.Diagnostics.AddError("Failed to create filter", err.Error())
@@ -187,6 +170,15 @@ func (r *FilterResource) Create(ctx context.Context, req resource.CreateRequest,
 
 	data.ID = types.StringValue(result.Id)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	if resp.Diagnostics.HasError() || query == "" {
+		return
+	}
+
+	// The filter is kept in state when this fails, but is tainted so that
+	// the next apply recreates it and tries again.
+	if err := r.applyToExisting(ctx, result, query, data.ApplyToExistingLimit.ValueInt64()); err != nil {
+		resp.Diagnostics.AddError("Failed to apply filter to existing messages", err.Error())
+	}
 }
 
 func (r *FilterResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
@@ -207,6 +199,12 @@ func (r *FilterResource) Read(ctx context.Context, req resource.ReadRequest, res
 	}
 
 	// Keep the existing values from state (Gmail API returns the same values)
+	if data.DeletionPolicy.IsNull() {
+		data.DeletionPolicy = types.StringValue(deletionPolicyDelete)
+	}
+	if data.ApplyToExisting.IsNull() {
+		data.ApplyToExisting = types.BoolValue(false)
+	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -235,6 +233,10 @@ func (r *FilterResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 
+	if data.DeletionPolicy.ValueString() == deletionPolicyAbandon {
+		return
+	}
+
 	err := r.config.gmailService.Users.Settings.Filters.Delete(gmailUser, data.ID.ValueString()).Do()
 	if err != nil && !isNotFoundError(err) {
 		resp.Diagnostics.AddError("Failed to delete filter", err.Error())
@@ -242,6 +244,135 @@ func (r *FilterResource) Delete(ctx context.Context, req resource.DeleteRequest,
 	}
 }
 
+func (r *FilterResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan FilterResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() || plan.Action.IsNull() || plan.Action.IsUnknown() {
+		return
+	}
+
+	var action struct {
+		AddLabelIds    []types.String `tfsdk:"add_label_ids"`
+		Forward        types.String   `tfsdk:"forward"`
+		RemoveLabelIds []types.String `tfsdk:"remove_label_ids"`
+	}
+	resp.Diagnostics.Append(plan.Action.As(ctx, &action, basetypes.ObjectAsOptions{UnhandledUnknownAsEmpty: true})...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if len(action.AddLabelIds) == 0 && len(action.RemoveLabelIds) == 0 {
+		return
+	}
+
+	// A filter is only applied to existing messages when it is created,
+	// which a criteria change always does.
+	if plan.ApplyToExisting.ValueBool() && criteriaObjectKnown(plan.Criteria) {
+		var state FilterResourceModel
+		if !req.State.Raw.IsNull() {
+			resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		}
+		if req.State.Raw.IsNull() || !plan.Criteria.Equal(state.Criteria) {
+			var criteria FilterCriteriaModel
+			resp.Diagnostics.Append(plan.Criteria.As(ctx, &criteria, basetypes.ObjectAsOptions{})...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+			if _, err := existingMessagesQuery(convertCriteriaToGmailAPI(ctx, criteria, &resp.Diagnostics)); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("criteria"), "Cannot apply filter to existing messages", err.Error())
+				return
+			}
+		}
+	}
+
+	if r.config == nil {
+		return
+	}
+
+	// IDs that are still unknown belong to labels created in the same plan.
+	for name, ids := range map[string][]types.String{
+		"add_label_ids":    action.AddLabelIds,
+		"remove_label_ids": action.RemoveLabelIds,
+	} {
+		for i, id := range ids {
+			if id.IsUnknown() || id.IsNull() {
+				continue
+			}
+			exists, err := r.config.labelExists(id.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError("Failed to list labels", err.Error())
+				return
+			}
+			if exists {
+				continue
+			}
+			resp.Diagnostics.AddAttributeError(path.Root("action").AtName(name).AtListIndex(i), "Unknown label ID",
+				fmt.Sprintf("No label with ID %q exists in the mailbox", id.ValueString()))
+		}
+	}
+}
+
+// actionChangesLabels reports whether action adds or removes labels, which
+// is all apply_to_existing does.
+func actionChangesLabels(action *gmail.FilterAction) bool {
+	return action != nil && (len(action.AddLabelIds) > 0 || len(action.RemoveLabelIds) > 0)
+}
+
+// existingMessagesQuery returns the search for the existing messages that a
+// filter with criteria applies to. Criteria that compile to an empty query
+// are rejected, as they would match every message.
+func existingMessagesQuery(criteria *gmail.FilterCriteria) (string, error) {
+	query, err := criteriaQuery(criteria)
+	if err != nil {
+		return "", err
+	}
+	if query == "" {
+		return "", fmt.Errorf("the filter has no criteria to search for")
+	}
+	return query, nil
+}
+
+// criteriaObjectKnown reports whether no attribute of a criteria object is
+// unknown, so that its query can be compiled.
+func criteriaObjectKnown(criteria types.Object) bool {
+	if criteria.IsNull() || criteria.IsUnknown() {
+		return false
+	}
+	for _, v := range criteria.Attributes() {
+		if v.IsUnknown() {
+			return false
+		}
+	}
+	return true
+}
+
+// applyToExisting adds and removes the labels of filter's action on the
+// existing messages matching query, at most limit of them if limit is
+// greater than zero.
+func (r *FilterResource) applyToExisting(ctx context.Context, filter *gmail.Filter, query string, limit int64) error {
+	ids, err := searchMessageIDs(ctx, r.config.gmailService, query, nil, int(limit))
+	if err != nil {
+		return fmt.Errorf("searching for %q: %w", query, err)
+	}
+	tflog.Info(ctx, "Applying filter to existing messages", map[string]any{
+		"filter_id": filter.Id,
+		"query":     query,
+		"messages":  len(ids),
+		"limit":     limit,
+	})
+
+	return batchModifyMessages(ctx, r.config.gmailService, ids, filter.Action.AddLabelIds, filter.Action.RemoveLabelIds, func(done int) {
+		tflog.Info(ctx, "Applied filter to existing messages", map[string]any{
+			"filter_id": filter.Id,
+			"done":      done,
+			"total":     len(ids),
+		})
+	})
+}
+
 func (r *FilterResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
 }
@@ -332,24 +463,10 @@ func (r *FilterResource) UpgradeState(ctx context.Context) map[int64]resource.St
 				}
 
 				// Convert first element of each list to object
-				actionObj, diags := types.ObjectValueFrom(ctx, map[string]attr.Type{
-					"add_label_ids":    types.ListType{ElemType: types.StringType},
-					"forward":          types.StringType,
-					"remove_label_ids": types.ListType{ElemType: types.StringType},
-				}, actions[0])
+				actionObj, diags := types.ObjectValueFrom(ctx, filterActionAttrTypes, actions[0])
 				resp.Diagnostics.Append(diags...)
 
-				criteriaObj, diags := types.ObjectValueFrom(ctx, map[string]attr.Type{
-					"exclude_chats":   types.BoolType,
-					"from":            types.StringType,
-					"has_attachment":  types.BoolType,
-					"negated_query":   types.StringType,
-					"query":           types.StringType,
-					"size":            types.Int64Type,
-					"size_comparison": types.StringType,
-					"subject":         types.StringType,
-					"to":              types.StringType,
-				}, criterias[0])
+				criteriaObj, diags := types.ObjectValueFrom(ctx, filterCriteriaAttrTypes, criterias[0])
 				resp.Diagnostics.Append(diags...)
 
 				if resp.Diagnostics.HasError() {
@@ -358,9 +475,12 @@ func (r *FilterResource) UpgradeState(ctx context.Context) map[int64]resource.St
 
 				// Create new model with objects
 				newData := FilterResourceModel{
-					ID:       oldData.ID,
-					Action:   actionObj,
-					Criteria: criteriaObj,
+					ID:                   oldData.ID,
+					DeletionPolicy:       types.StringValue(deletionPolicyDelete),
+					ApplyToExisting:      types.BoolValue(false),
+					ApplyToExistingLimit: types.Int64Null(),
+					Action:               actionObj,
+					Criteria:             criteriaObj,
 				}
 
 				resp.Diagnostics.Append(resp.State.Set(ctx, newData)...)
@@ -370,6 +490,103 @@ func (r *FilterResource) UpgradeState(ctx context.Context) map[int64]resource.St
 }
 
 // Helper functions
+var filterActionAttrTypes = map[string]attr.Type{
+	"add_label_ids":    types.ListType{ElemType: types.StringType},
+	"forward":          types.StringType,
+	"remove_label_ids": types.ListType{ElemType: types.StringType},
+}
+
+var filterCriteriaAttrTypes = map[string]attr.Type{
+	"exclude_chats":   types.BoolType,
+	"from":            types.StringType,
+	"has_attachment":  types.BoolType,
+	"negated_query":   types.StringType,
+	"query":           types.StringType,
+	"size":            types.Int64Type,
+	"size_comparison": types.StringType,
+	"subject":         types.StringType,
+	"to":              types.StringType,
+}
+
+const (
+	deletionPolicyDelete  = "DELETE"
+	deletionPolicyAbandon = "ABANDON"
+)
+
+// deletionPolicyAttribute returns the deletion_policy attribute shared by
+// resources that can leave their object behind in Gmail when destroyed.
+func deletionPolicyAttribute(kind string) schema.StringAttribute {
+	return schema.StringAttribute{
+		Optional:    true,
+		Computed:    true,
+		Default:     stringdefault.StaticString(deletionPolicyDelete),
+		Description: fmt.Sprintf("What happens to the %[1]s in Gmail when the resource is destroyed: DELETE removes the %[1]s, ABANDON only removes it from Terraform state", kind),
+		Validators: []validator.String{
+			stringOneOf(deletionPolicyDelete, deletionPolicyAbandon),
+		},
+	}
+}
+
+func filterActionAttributes() map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"add_label_ids": schema.ListAttribute{
+			ElementType: types.StringType,
+			Optional:    true,
+			Description: "List of labels to add to the message",
+		},
+		"forward": schema.StringAttribute{
+			Optional:    true,
+			Description: "Email address that the message should be forwarded to",
+		},
+		"remove_label_ids": schema.ListAttribute{
+			ElementType: types.StringType,
+			Optional:    true,
+			Description: "List of labels to remove from the message",
+		},
+	}
+}
+
+func filterCriteriaAttributes() map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"exclude_chats": schema.BoolAttribute{
+			Optional:    true,
+			Description: "Whether the response should exclude chats",
+		},
+		"from": schema.StringAttribute{
+			Optional:    true,
+			Description: "The sender's display name or email address",
+		},
+		"has_attachment": schema.BoolAttribute{
+			Optional:    true,
+			Description: "Whether the message has any attachment",
+		},
+		"negated_query": schema.StringAttribute{
+			Optional:    true,
+			Description: "Only return messages not matching the specified query",
+		},
+		"query": schema.StringAttribute{
+			Optional:    true,
+			Description: "Only return messages matching the specified query",
+		},
+		"size": schema.Int64Attribute{
+			Optional:    true,
+			Description: "The size of the entire RFC822 message in bytes",
+		},
+		"size_comparison": schema.StringAttribute{
+			Optional:    true,
+			Description: "How the message size should be compared (larger/smaller/unspecified)",
+		},
+		"subject": schema.StringAttribute{
+			Optional:    true,
+			Description: "Case-insensitive phrase found in the message's subject",
+		},
+		"to": schema.StringAttribute{
+			Optional:    true,
+			Description: "The recipient's display name or email address",
+		},
+	}
+}
+
 func convertActionToGmailAPI(ctx context.Context, action FilterActionModel, diags *diag.Diagnostics) *gmail.FilterAction {
 	var addLabelIds []string
 	var removeLabelIds []string
@@ -407,3 +624,123 @@ func isNotFoundError(err error) bool {
 	return err != nil && (err.Error() == "googleapi: Error 404: Not Found" ||
 		fmt.Sprintf("%v", err) == "googleapi: Error 404: Not Found")
 }
+
+func listFilters(svc *gmail.Service) ([]*gmail.Filter, error) {
+	res, err := svc.Users.Settings.Filters.List(gmailUser).Do()
+	if err != nil {
+		return nil, err
+	}
+	return res.Filter, nil
+}
+
+// filterFingerprint returns a key that is equal for two filters with the same
+// action and criteria, regardless of their IDs or label ordering.
+func filterFingerprint(f *gmail.Filter) string {
+	var action gmail.FilterAction
+	var criteria gmail.FilterCriteria
+	if f.Action != nil {
+		action = *f.Action
+	}
+	if f.Criteria != nil {
+		criteria = *f.Criteria
+	}
+	action.AddLabelIds = sortedCopy(action.AddLabelIds)
+	action.RemoveLabelIds = sortedCopy(action.RemoveLabelIds)
+	b, _ := json.Marshal(struct {
+		Action   gmail.FilterAction
+		Criteria gmail.FilterCriteria
+	}{action, criteria})
+	return string(b)
+}
+
+// filterReferencesLabel reports whether f adds or removes the label labelID.
+func filterReferencesLabel(f *gmail.Filter, labelID string) bool {
+	if f.Action == nil {
+		return false
+	}
+	return containsString(f.Action.AddLabelIds, labelID) || containsString(f.Action.RemoveLabelIds, labelID)
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutString(s []string, v string) []string {
+	var out []string
+	for _, e := range s {
+		if e != v {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func sortedCopy(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	c := append([]string(nil), s...)
+	sort.Strings(c)
+	return c
+}
+
+// flattenFilterAction converts an API filter action into the object used by
+// the filter resources. Empty values are stored as null so they line up with
+// attributes that were left unset in configuration.
+func flattenFilterAction(ctx context.Context, action *gmail.FilterAction) (types.Object, diag.Diagnostics) {
+	if action == nil {
+		action = &gmail.FilterAction{}
+	}
+	return types.ObjectValueFrom(ctx, filterActionAttrTypes, FilterActionModel{
+		AddLabelIds:    convertStringSliceToList(ctx, nilIfEmpty(action.AddLabelIds)),
+		Forward:        stringOrNull(action.Forward),
+		RemoveLabelIds: convertStringSliceToList(ctx, nilIfEmpty(action.RemoveLabelIds)),
+	})
+}
+
+// flattenFilterCriteria is the criteria counterpart of flattenFilterAction.
+func flattenFilterCriteria(ctx context.Context, criteria *gmail.FilterCriteria) (types.Object, diag.Diagnostics) {
+	if criteria == nil {
+		criteria = &gmail.FilterCriteria{}
+	}
+	model := FilterCriteriaModel{
+		ExcludeChats:   types.BoolNull(),
+		From:           stringOrNull(criteria.From),
+		HasAttachment:  types.BoolNull(),
+		NegatedQuery:   stringOrNull(criteria.NegatedQuery),
+		Query:          stringOrNull(criteria.Query),
+		Size:           types.Int64Null(),
+		SizeComparison: stringOrNull(criteria.SizeComparison),
+		Subject:        stringOrNull(criteria.Subject),
+		To:             stringOrNull(criteria.To),
+	}
+	if criteria.ExcludeChats {
+		model.ExcludeChats = types.BoolValue(true)
+	}
+	if criteria.HasAttachment {
+		model.HasAttachment = types.BoolValue(true)
+	}
+	if criteria.Size != 0 {
+		model.Size = types.Int64Value(criteria.Size)
+	}
+	return types.ObjectValueFrom(ctx, filterCriteriaAttrTypes, model)
+}
+
+func stringOrNull(s string) types.String {
+	if s == "" {
+		return types.StringNull()
+	}
+	return types.StringValue(s)
+}
+
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,370 @@
+package gmailfilter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"google.golang.org/api/gmail/v1"
+)
+
+var _ resource.Resource = &FilterSetResource{}
+var _ resource.ResourceWithImportState = &FilterSetResource{}
+
+func NewFilterSetResource() resource.Resource {
+	return &FilterSetResource{}
+}
+
+type FilterSetResource struct {
+	config *Config
+}
+
+type FilterSetResourceModel struct {
+	ID              types.String `tfsdk:"id"`
+	DeleteUnmanaged types.Bool   `tfsdk:"delete_unmanaged"`
+	FilterIDs       types.Set    `tfsdk:"filter_ids"`
+	Filters         types.Set    `tfsdk:"filter"`
+}
+
+type FilterSetFilterModel struct {
+	Action   types.Object `tfsdk:"action"`
+	Criteria types.Object `tfsdk:"criteria"`
+}
+
+var filterSetFilterAttrTypes = map[string]attr.Type{
+	"action":   types.ObjectType{AttrTypes: filterActionAttrTypes},
+	"criteria": types.ObjectType{AttrTypes: filterCriteriaAttrTypes},
+}
+
+func (r *FilterSetResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_filter_set"
+}
+
+func (r *FilterSetResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages the complete set of Gmail filters for the mailbox. Only one of these should exist per mailbox.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The mailbox the filters belong to",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"delete_unmanaged": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether filters that are not part of this set should be deleted",
+			},
+			"filter_ids": schema.SetAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Description: "The IDs of the filters managed by this set",
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"filter": schema.SetNestedBlock{
+				Description: "A filter that should exist in the mailbox. Filters cannot be modified in place, so a changed filter is deleted and created again.",
+				NestedObject: schema.NestedBlockObject{
+					Blocks: map[string]schema.Block{
+						"action": schema.SingleNestedBlock{
+							Description: "Action that the filter performs",
+							Attributes:  filterActionAttributes(),
+						},
+						"criteria": schema.SingleNestedBlock{
+							Description: "The criteria that a message should match to apply the filter",
+							Attributes:  filterCriteriaAttributes(),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *FilterSetResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Resource Configure Type", "Expected *Config")
+		return
+	}
+	r.config = config
+}
+
+func (r *FilterSetResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data FilterSetResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// State is saved even when apply fails part way, so that the filters it
+	// created are tracked instead of being orphaned.
+	r.apply(ctx, &data, nil, &resp.Diagnostics)
+	data.ID = types.StringValue(gmailUser)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *FilterSetResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data FilterSetResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	existing, err := listFilters(r.config.gmailService)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list filters", err.Error())
+		return
+	}
+
+	// A null ID set means the resource was just imported, in which case every
+	// filter in the mailbox is taken over.
+	imported := data.FilterIDs.IsNull()
+	managed := map[string]bool{}
+	if !imported {
+		var ids []string
+		resp.Diagnostics.Append(data.FilterIDs.ElementsAs(ctx, &ids, false)...)
+		for _, id := range ids {
+			managed[id] = true
+		}
+	}
+	if data.DeleteUnmanaged.IsNull() {
+		data.DeleteUnmanaged = types.BoolValue(false)
+	}
+
+	// Keep the configured representation of filters that still exist, so
+	// that values Gmail does not echo back (such as false booleans) do not
+	// show up as a diff.
+	prior := map[string]FilterSetFilterModel{}
+	if !data.Filters.IsNull() {
+		var filters []FilterSetFilterModel
+		resp.Diagnostics.Append(data.Filters.ElementsAs(ctx, &filters, false)...)
+		for _, f := range filters {
+			gf := expandFilterSetFilter(ctx, f, &resp.Diagnostics)
+			prior[filterFingerprint(gf)] = f
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var ids []string
+	filters := []FilterSetFilterModel{}
+	for _, f := range existing {
+		isManaged := imported || managed[f.Id]
+		if !isManaged && !data.DeleteUnmanaged.ValueBool() {
+			continue
+		}
+		if isManaged {
+			ids = append(ids, f.Id)
+		}
+		if p, ok := prior[filterFingerprint(f)]; ok && isManaged {
+			filters = append(filters, p)
+			continue
+		}
+		// Unmanaged filters are reported so that the plan shows their removal.
+		filters = append(filters, flattenFilterSetFilter(ctx, f, &resp.Diagnostics))
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	data.FilterIDs = setOfStrings(ctx, ids, &resp.Diagnostics)
+	filterSet, diags := types.SetValueFrom(ctx, types.ObjectType{AttrTypes: filterSetFilterAttrTypes}, filters)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Filters = filterSet
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *FilterSetResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state FilterSetResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var ids []string
+	resp.Diagnostics.Append(state.FilterIDs.ElementsAs(ctx, &ids, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, ids, &resp.Diagnostics)
+	plan.ID = state.ID
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *FilterSetResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data FilterSetResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var ids []string
+	resp.Diagnostics.Append(data.FilterIDs.ElementsAs(ctx, &ids, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	for _, id := range ids {
+		err := r.config.gmailService.Users.Settings.Filters.Delete(gmailUser, id).Do()
+		if err != nil && !isNotFoundError(err) {
+			resp.Diagnostics.AddError("Failed to delete filter", fmt.Sprintf("Filter %s: %s", id, err))
+		}
+	}
+}
+
+func (r *FilterSetResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+// apply reconciles the filters in the mailbox with the desired set in data.
+// Filters that already exist unchanged are kept, missing ones are created and
+// previously managed ones that are no longer desired are deleted. When
+// delete_unmanaged is set, every other filter is deleted as well. On return
+// data.FilterIDs holds the IDs of the filters that make up the set, which on
+// failure includes every filter created so far and every managed filter that
+// could not be deleted.
+func (r *FilterSetResource) apply(ctx context.Context, data *FilterSetResourceModel, managedIDs []string, diags *diag.Diagnostics) {
+	var desired []FilterSetFilterModel
+	if !data.Filters.IsNull() && !data.Filters.IsUnknown() {
+		diags.Append(data.Filters.ElementsAs(ctx, &desired, false)...)
+	}
+	if diags.HasError() {
+		data.FilterIDs = setOfStrings(ctx, managedIDs, diags)
+		return
+	}
+
+	existing, err := listFilters(r.config.gmailService)
+	if err != nil {
+		diags.AddError("Failed to list filters", err.Error())
+		data.FilterIDs = setOfStrings(ctx, managedIDs, diags)
+		return
+	}
+
+	managed := map[string]bool{}
+	for _, id := range managedIDs {
+		managed[id] = true
+	}
+
+	// Index existing filters by fingerprint, managed ones first so they are
+	// preferred over identical unmanaged filters.
+	byFingerprint := map[string][]*gmail.Filter{}
+	for _, pass := range []bool{true, false} {
+		for _, f := range existing {
+			if managed[f.Id] == pass {
+				fp := filterFingerprint(f)
+				byFingerprint[fp] = append(byFingerprint[fp], f)
+			}
+		}
+	}
+
+	kept := map[string]bool{}
+	var ids []string
+	for _, d := range desired {
+		filter := expandFilterSetFilter(ctx, d, diags)
+		if diags.HasError() {
+			data.FilterIDs = setOfStrings(ctx, append(ids, unkeptManaged(existing, managed, kept)...), diags)
+			return
+		}
+
+		fp := filterFingerprint(filter)
+		if candidates := byFingerprint[fp]; len(candidates) > 0 {
+			kept[candidates[0].Id] = true
+			ids = append(ids, candidates[0].Id)
+			byFingerprint[fp] = candidates[1:]
+			continue
+		}
+
+		result, err := r.config.gmailService.Users.Settings.Filters.Create(gmailUser, filter).Do()
+		if err != nil {
+			diags.AddError("Failed to create filter", err.Error())
+			// Nothing has been deleted yet, so the previously managed filters
+			// still belong to the set alongside the new ones.
+			data.FilterIDs = setOfStrings(ctx, append(ids, unkeptManaged(existing, managed, kept)...), diags)
+			return
+		}
+		ids = append(ids, result.Id)
+	}
+
+	for _, f := range existing {
+		if kept[f.Id] || (!managed[f.Id] && !data.DeleteUnmanaged.ValueBool()) {
+			continue
+		}
+		err := r.config.gmailService.Users.Settings.Filters.Delete(gmailUser, f.Id).Do()
+		if err != nil && !isNotFoundError(err) {
+			diags.AddError("Failed to delete filter", fmt.Sprintf("Filter %s: %s", f.Id, err))
+			// Keep tracking a managed filter that is still there, so the next
+			// apply retries its deletion.
+			if managed[f.Id] {
+				ids = append(ids, f.Id)
+			}
+		}
+	}
+
+	data.FilterIDs = setOfStrings(ctx, ids, diags)
+}
+
+// unkeptManaged returns the IDs of the managed filters in existing that are
+// not in kept.
+func unkeptManaged(existing []*gmail.Filter, managed, kept map[string]bool) []string {
+	var ids []string
+	for _, f := range existing {
+		if managed[f.Id] && !kept[f.Id] {
+			ids = append(ids, f.Id)
+		}
+	}
+	return ids
+}
+
+func expandFilterSetFilter(ctx context.Context, f FilterSetFilterModel, diags *diag.Diagnostics) *gmail.Filter {
+	var action FilterActionModel
+	var criteria FilterCriteriaModel
+	if !f.Action.IsNull() {
+		diags.Append(f.Action.As(ctx, &action, basetypes.ObjectAsOptions{})...)
+	}
+	if !f.Criteria.IsNull() {
+		diags.Append(f.Criteria.As(ctx, &criteria, basetypes.ObjectAsOptions{})...)
+	}
+	return &gmail.Filter{
+		Action:   convertActionToGmailAPI(ctx, action, diags),
+		Criteria: convertCriteriaToGmailAPI(ctx, criteria, diags),
+	}
+}
+
+func flattenFilterSetFilter(ctx context.Context, f *gmail.Filter, diags *diag.Diagnostics) FilterSetFilterModel {
+	action, d := flattenFilterAction(ctx, f.Action)
+	diags.Append(d...)
+	criteria, d := flattenFilterCriteria(ctx, f.Criteria)
+	diags.Append(d...)
+	return FilterSetFilterModel{
+		Action:   action,
+		Criteria: criteria,
+	}
+}
+
+func setOfStrings(ctx context.Context, s []string, diags *diag.Diagnostics) types.Set {
+	set, d := types.SetValueFrom(ctx, types.StringType, append([]string{}, s...))
+	diags.Append(d...)
+	return set
+}
@@ -2,18 +2,31 @@ package gmailfilter
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 	"google.golang.org/api/gmail/v1"
 )
 
 var _ resource.Resource = &LabelResource{}
 var _ resource.ResourceWithImportState = &LabelResource{}
+var _ resource.ResourceWithModifyPlan = &LabelResource{}
+var _ resource.ResourceWithValidateConfig = &LabelResource{}
 
 func NewLabelResource() resource.Resource {
 	return &LabelResource{}
@@ -26,15 +39,37 @@ type LabelResource struct {
 type LabelResourceModel struct {
 	ID                    types.String `tfsdk:"id"`
 	Name                  types.String `tfsdk:"name"`
+	ParentID              types.String `tfsdk:"parent_id"`
+	Path                  types.String `tfsdk:"path"`
+	PathParts             types.List   `tfsdk:"path_parts"`
+	Depth                 types.Int64  `tfsdk:"depth"`
+	Color                 types.Object `tfsdk:"color"`
 	BackgroundColor       types.String `tfsdk:"background_color"`
 	TextColor             types.String `tfsdk:"text_color"`
 	LabelListVisibility   types.String `tfsdk:"label_list_visibility"`
 	MessageListVisibility types.String `tfsdk:"message_list_visibility"`
-	MessagesTotal         types.Int64  `tfsdk:"messages_total"`
-	MessagesUnread        types.Int64  `tfsdk:"messages_unread"`
-	ThreadsTotal          types.Int64  `tfsdk:"threads_total"`
-	ThreadsUnread         types.Int64  `tfsdk:"threads_unread"`
 	Type                  types.String `tfsdk:"type"`
+	DeletionPolicy        types.String `tfsdk:"deletion_policy"`
+	ForceDestroy          types.Bool   `tfsdk:"force_destroy"`
+	OnDeleteRelabelTo     types.String `tfsdk:"on_delete_relabel_to"`
+	AdoptExisting         types.Bool   `tfsdk:"adopt_existing"`
+	OnDeleteFilters       types.String `tfsdk:"on_delete_filters"`
+}
+
+type LabelColorModel struct {
+	Background    types.String `tfsdk:"background"`
+	Text          types.String `tfsdk:"text"`
+	Auto          types.Bool   `tfsdk:"auto"`
+	BackgroundHex types.String `tfsdk:"background_hex"`
+	TextHex       types.String `tfsdk:"text_hex"`
+}
+
+var labelColorAttrTypes = map[string]attr.Type{
+	"background":     types.StringType,
+	"text":           types.StringType,
+	"auto":           types.BoolType,
+	"background_hex": types.StringType,
+	"text_hex":       types.StringType,
 }
 
 func (r *LabelResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -43,7 +78,7 @@ func (r *LabelResource) Metadata(ctx context.Context, req resource.MetadataReque
 
 func (r *LabelResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		Description: "Manages a Gmail label",
+		Description: "Manages a Gmail label. Message and thread counts are available from the gmailfilter_label_stats data source.",
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
 				Computed:    true,
@@ -54,45 +89,126 @@ func (r *LabelResource) Schema(ctx context.Context, req resource.SchemaRequest,
 			},
 			"name": schema.StringAttribute{
 				Required:    true,
-				Description: "The display name of the label",
+				Description: "The display name of the label. When parent_id is set this is the leaf name below the parent, otherwise it is the full slash-separated name. Renaming a label also renames the labels nested below it; nested labels managed by their own gmailfilter_label should therefore use parent_id, as a full name in their configuration would no longer match.",
+				Validators: []validator.String{
+					labelNameValidator{},
+				},
+			},
+			"parent_id": schema.StringAttribute{
+				Optional:    true,
+				Description: "The ID of the label to nest this label under",
+			},
+			"path": schema.StringAttribute{
+				Computed:    true,
+				Description: "The full slash-separated name of the label in Gmail",
+			},
+			"path_parts": schema.ListAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Description: "The components of path, from the top-level label down to this one",
+			},
+			"depth": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of labels above this one in the hierarchy (0 for a top-level label)",
+			},
+			"color": schema.SingleNestedAttribute{
+				Optional:    true,
+				Description: "The color of the label. Colors must come from the Gmail palette and can be given as hex string or palette name such as \"blue-light\".",
+				Attributes: map[string]schema.Attribute{
+					"background": schema.StringAttribute{
+						Optional:    true,
+						Description: "The background color, as hex string or palette name",
+						Validators: []validator.String{
+							labelColorValidator{},
+						},
+					},
+					"text": schema.StringAttribute{
+						Optional:    true,
+						Description: "The text color, as hex string or palette name. Defaults to black or white, whichever contrasts best with the background.",
+						Validators: []validator.String{
+							labelColorValidator{},
+						},
+					},
+					"auto": schema.BoolAttribute{
+						Optional:    true,
+						Description: "Pick the background color from the palette based on the label name, so the same name always gets the same color",
+					},
+					"background_hex": schema.StringAttribute{
+						Computed:    true,
+						Description: "The background color as hex string",
+					},
+					"text_hex": schema.StringAttribute{
+						Computed:    true,
+						Description: "The text color as hex string",
+					},
+				},
 			},
 			"background_color": schema.StringAttribute{
 				Optional:    true,
-				Description: "The background color represented as hex string #RRGGBB",
+				Description: "The background color represented as hex string #RRGGBB. Must be set together with text_color.",
+				Validators: []validator.String{
+					labelColorValidator{},
+				},
 			},
 			"text_color": schema.StringAttribute{
 				Optional:    true,
-				Description: "The text color of the label, represented as hex string",
+				Description: "The text color of the label, represented as hex string. Must be set together with background_color.",
+				Validators: []validator.String{
+					labelColorValidator{},
+				},
 			},
 			"label_list_visibility": schema.StringAttribute{
 				Optional:    true,
 				Computed:    true,
-				Description: "The visibility of the label in the label list in the Gmail web interface",
+				Description: "The visibility of the label in the label list in the Gmail web interface (labelShow/labelShowIfUnread/labelHide)",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+				Validators: []validator.String{
+					stringOneOf(labelListVisibilityValues...),
+				},
 			},
 			"message_list_visibility": schema.StringAttribute{
 				Optional:    true,
 				Computed:    true,
-				Description: "The visibility of messages with this label in the message list",
+				Description: "The visibility of messages with this label in the message list (show/hide)",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+				Validators: []validator.String{
+					stringOneOf(messageListVisibilityValues...),
+				},
 			},
-			"messages_total": schema.Int64Attribute{
+			"type": schema.StringAttribute{
 				Computed:    true,
-				Description: "The total number of messages with the label",
+				Description: "The owner type for the label (user or system)",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
-			"messages_unread": schema.Int64Attribute{
+			"deletion_policy": deletionPolicyAttribute("label"),
+			"force_destroy": schema.BoolAttribute{
+				Optional:    true,
 				Computed:    true,
-				Description: "The number of unread messages with the label",
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether the label may be deleted while it still contains messages",
 			},
-			"threads_total": schema.Int64Attribute{
-				Computed:    true,
-				Description: "The total number of threads with the label",
+			"on_delete_relabel_to": schema.StringAttribute{
+				Optional:    true,
+				Description: "The ID of a label to move the messages of this label to before it is deleted, so they do not end up unlabeled",
 			},
-			"threads_unread": schema.Int64Attribute{
-				Computed:    true,
-				Description: "The number of unread threads with the label",
+			"adopt_existing": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Take over a label that already exists with the same name instead of failing to create it. The configured color and visibility are applied to the existing label, while those left unconfigured keep their current values.",
 			},
-			"type": schema.StringAttribute{
+			"on_delete_filters": schema.StringAttribute{
+				Optional:    true,
 				Computed:    true,
-				Description: "The owner type for the label (user or system)",
+				Default:     stringdefault.StaticString(onDeleteFiltersError),
+				Description: "What to do with filters that add or remove this label when it is deleted: ERROR refuses to delete the label, DELETE deletes those filters and DETACH recreates them without the label. Recreated filters get new IDs, so gmailfilter_filter resources managing them will be recreated as well.",
+				Validators: []validator.String{
+					stringOneOf(onDeleteFiltersError, onDeleteFiltersDelete, onDeleteFiltersDetach),
+				},
 			},
 		},
 	}
@@ -117,29 +233,75 @@ func (r *LabelResource) Create(ctx context.Context, req resource.CreateRequest,
 		return
 	}
 
+	parentName, err := r.parentName(data)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read parent label", err.Error())
+		return
+	}
+
 	label := &gmail.Label{
-		Name:                  data.Name.ValueString(),
+		Name:                  labelPath(parentName, data.Name.ValueString()),
 		LabelListVisibility:   data.LabelListVisibility.ValueString(),
 		MessageListVisibility: data.MessageListVisibility.ValueString(),
 	}
 
-	// Add color if both background and text colors are provided
-	if !data.BackgroundColor.IsNull() && !data.TextColor.IsNull() {
-		label.Color = &gmail.LabelColor{
-			BackgroundColor: data.BackgroundColor.ValueString(),
-			TextColor:       data.TextColor.ValueString(),
+	label.Color = expandLabelColor(ctx, data, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var result *gmail.Label
+	unmanagedColor := false
+	if data.AdoptExisting.ValueBool() {
+		existing, err := r.findLabelByName(label.Name)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to list labels", err.Error())
+			return
+		}
+		if existing != nil {
+			if existing.Type != "user" {
+				resp.Diagnostics.AddError("Cannot adopt system label",
+					fmt.Sprintf("Label %q (ID %s) is a %s label and cannot be managed by gmailfilter_label", existing.Name, existing.Id, existing.Type))
+				return
+			}
+			result, err = r.config.gmailService.Users.Labels.Get(gmailUser, existing.Id).Do()
+			if err != nil {
+				resp.Diagnostics.AddError("Failed to read label", err.Error())
+				return
+			}
+			// Only the configured fields are applied to an adopted label;
+			// nothing about it was managed before, so nothing is cleared.
+			if patch := labelPatch(ctx, data, label.Name, result, false, false, &resp.Diagnostics); patch != nil {
+				result, err = r.config.gmailService.Users.Labels.Patch(gmailUser, existing.Id, patch).Do()
+				if err != nil {
+					resp.Diagnostics.AddError("Failed to update label", err.Error())
+					return
+				}
+			}
+			unmanagedColor = label.Color == nil
+			resp.Diagnostics.AddWarning("Adopted existing label",
+				fmt.Sprintf("Label %q already existed with ID %s and is now managed by Terraform. Destroying this resource will delete it unless deletion_policy is set to ABANDON.", existing.Name, existing.Id))
 		}
 	}
 
-	result, err := r.config.gmailService.Users.Labels.Create(gmailUser, label).Do()
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to create label", err.Error())
-		return
+	if result == nil {
+		result, err = r.config.gmailService.Users.Labels.Create(gmailUser, label).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to create label", err.Error())
+			return
+		}
+		r.config.forgetLabels()
 	}
 
 	// Update model with computed values
 	data.ID = types.StringValue(result.Id)
-	r.updateModelFromAPIResponse(&data, result)
+	r.updateModelFromAPIResponse(ctx, &data, result, parentName, &resp.Diagnostics)
+	if unmanagedColor {
+		data.BackgroundColor = types.StringNull()
+		data.TextColor = types.StringNull()
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, labelConfiguredKey, configuredLabelVisibility(ctx, req.Config, &resp.Diagnostics))...)
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, labelUnmanagedColorKey, unmanagedLabelColorValue(unmanagedColor))...)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -161,7 +323,35 @@ func (r *LabelResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	r.updateModelFromAPIResponse(&data, label)
+	parentName, err := r.parentName(data)
+	if err != nil {
+		if !isNotFoundError(err) {
+			resp.Diagnostics.AddError("Failed to read parent label", err.Error())
+			return
+		}
+		// The parent was deleted outside of Terraform. Report the label as
+		// top-level so the plan shows the drift instead of refresh failing.
+		data.ParentID = types.StringNull()
+		parentName = ""
+	}
+
+	r.updateModelFromAPIResponse(ctx, &data, label, parentName, &resp.Diagnostics)
+	if labelColorUnmanaged(ctx, req.Private, &resp.Diagnostics) {
+		data.BackgroundColor = types.StringNull()
+		data.TextColor = types.StringNull()
+	}
+
+	// Fill in defaults after import
+	if data.DeletionPolicy.IsNull() {
+		data.DeletionPolicy = types.StringValue(deletionPolicyDelete)
+	}
+	if data.ForceDestroy.IsNull() {
+		data.ForceDestroy = types.BoolValue(false)
+	}
+	if data.OnDeleteFilters.IsNull() {
+		data.OnDeleteFilters = types.StringValue(onDeleteFiltersError)
+	}
+
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
@@ -172,27 +362,54 @@ func (r *LabelResource) Update(ctx context.Context, req resource.UpdateRequest,
 		return
 	}
 
-	label := &gmail.Label{
-		Name:                  data.Name.ValueString(),
-		LabelListVisibility:   data.LabelListVisibility.ValueString(),
-		MessageListVisibility: data.MessageListVisibility.ValueString(),
+	parentName, err := r.parentName(data)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read parent label", err.Error())
+		return
 	}
 
-	// Add color if both background and text colors are provided
-	if !data.BackgroundColor.IsNull() && !data.TextColor.IsNull() {
-		label.Color = &gmail.LabelColor{
-			BackgroundColor: data.BackgroundColor.ValueString(),
-			TextColor:       data.TextColor.ValueString(),
-		}
+	current, err := r.config.gmailService.Users.Labels.Get(gmailUser, data.ID.ValueString()).Do()
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read label", err.Error())
+		return
 	}
 
-	result, err := r.config.gmailService.Users.Labels.Update(gmailUser, data.ID.ValueString(), label).Do()
-	if err != nil {
-		resp.Diagnostics.AddError("Failed to update label", err.Error())
+	// The color of an adopted label stays unmanaged until one is configured.
+	unmanagedColor := labelColorUnmanaged(ctx, req.Private, &resp.Diagnostics) && expandLabelColor(ctx, data, &resp.Diagnostics) == nil
+
+	result := current
+	if patch := labelPatch(ctx, data, labelPath(parentName, data.Name.ValueString()), current, true, !unmanagedColor, &resp.Diagnostics); patch != nil {
+		result, err = r.config.gmailService.Users.Labels.Patch(gmailUser, data.ID.ValueString(), patch).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to update label", err.Error())
+			return
+		}
+	}
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	r.updateModelFromAPIResponse(&data, result)
+	// Gmail does not rename nested labels along with their parent, so move
+	// them over to keep the hierarchy intact.
+	if current.Name != result.Name {
+		renamed, err := r.renameChildren(current.Name, result.Name)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to rename nested labels", err.Error())
+			return
+		}
+		if len(renamed) > 0 {
+			resp.Diagnostics.AddWarning("Renamed nested labels",
+				fmt.Sprintf("Moved %s below %q. Any of them managed by a gmailfilter_label with a full name instead of parent_id needs its name updated in the configuration, or the next apply will rename it back.", strings.Join(renamed, ", "), result.Name))
+		}
+	}
+
+	r.updateModelFromAPIResponse(ctx, &data, result, parentName, &resp.Diagnostics)
+	if unmanagedColor {
+		data.BackgroundColor = types.StringNull()
+		data.TextColor = types.StringNull()
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, labelConfiguredKey, configuredLabelVisibility(ctx, req.Config, &resp.Diagnostics))...)
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, labelUnmanagedColorKey, unmanagedLabelColorValue(unmanagedColor))...)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
@@ -203,7 +420,71 @@ func (r *LabelResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 
-	err := r.config.gmailService.Users.Labels.Delete(gmailUser, data.ID.ValueString()).Do()
+	if data.DeletionPolicy.ValueString() == deletionPolicyAbandon {
+		return
+	}
+
+	label, err := r.config.gmailService.Users.Labels.Get(gmailUser, data.ID.ValueString()).Do()
+	if err != nil {
+		if isNotFoundError(err) {
+			return
+		}
+		resp.Diagnostics.AddError("Failed to read label", err.Error())
+		return
+	}
+
+	// Run every check before anything is changed, so that a refused delete
+	// leaves filters and messages as they were.
+	if label.Type != "user" {
+		resp.Diagnostics.AddError("Refusing to delete system label",
+			fmt.Sprintf("Label %q (ID %s) is a %s label and cannot be deleted", label.Name, label.Id, label.Type))
+		return
+	}
+	target := data.OnDeleteRelabelTo.ValueString()
+	if target == "" && label.MessagesTotal > 0 && !data.ForceDestroy.ValueBool() {
+		resp.Diagnostics.AddError("Refusing to delete label that contains messages",
+			fmt.Sprintf("Label %q still contains %d messages. Set on_delete_relabel_to to move the messages elsewhere first, force_destroy to delete it anyway, or deletion_policy to ABANDON to keep it in Gmail.", label.Name, label.MessagesTotal))
+		return
+	}
+	if target != "" {
+		if target == label.Id {
+			resp.Diagnostics.AddAttributeError(path.Root("on_delete_relabel_to"), "Invalid relabel target",
+				fmt.Sprintf("Label %q cannot move its messages to itself", label.Name))
+			return
+		}
+		if _, err := r.config.gmailService.Users.Labels.Get(gmailUser, target).Do(); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("on_delete_relabel_to"), "Failed to read relabel target",
+				fmt.Sprintf("Label %s: %s", target, err))
+			return
+		}
+	}
+	referencing := r.referencingFilters(label, data.OnDeleteFilters.ValueString(), &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Move the messages first: a failure here leaves the filters intact, and
+	// filters cannot be restored once they have been changed.
+	if target != "" {
+		ids, err := searchMessageIDs(ctx, r.config.gmailService, "", []string{label.Id}, 0)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to list messages with label", err.Error())
+			return
+		}
+		err = batchModifyMessages(ctx, r.config.gmailService, ids, []string{target}, []string{label.Id}, nil)
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to relabel messages",
+				fmt.Sprintf("Moving messages from %q to %s: %s", label.Name, target, err))
+			return
+		}
+	}
+
+	r.cleanupReferencingFilters(label, referencing, data.OnDeleteFilters.ValueString(), &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	err = r.config.gmailService.Users.Labels.Delete(gmailUser, data.ID.ValueString()).Do()
 	if err != nil && !isNotFoundError(err) {
 		resp.Diagnostics.AddError("Failed to delete label", err.Error())
 		return
@@ -214,21 +495,508 @@ func (r *LabelResource) ImportState(ctx context.Context, req resource.ImportStat
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
 }
 
-func (r *LabelResource) updateModelFromAPIResponse(data *LabelResourceModel, label *gmail.Label) {
+func (r *LabelResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan LabelResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Without a parent the path is known up front. With a parent it only
+	// changes when the name or the parent does.
+	if plan.ParentID.IsNull() && !plan.Name.IsUnknown() {
+		setLabelPath(ctx, &plan, plan.Name.ValueString(), &resp.Diagnostics)
+	} else if !req.State.Raw.IsNull() {
+		var state LabelResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if plan.Name.Equal(state.Name) && plan.ParentID.Equal(state.ParentID) {
+			plan.Path = state.Path
+			plan.PathParts = state.PathParts
+			plan.Depth = state.Depth
+		}
+	}
+
+	// The name validator only sees the leaf name. Check the full name once
+	// the parent is known; a parent that cannot be read fails the apply
+	// anyway.
+	if !plan.ParentID.IsNull() && !plan.ParentID.IsUnknown() && !plan.Name.IsUnknown() && r.config != nil {
+		if parentName, err := r.parentName(plan); err == nil {
+			fullName := labelPath(parentName, plan.Name.ValueString())
+			if n := len([]rune(fullName)); n > maxLabelNameLength {
+				resp.Diagnostics.AddAttributeError(path.Root("name"), "Invalid label name",
+					fmt.Sprintf("The full name %q is %d characters long, Gmail allows at most %d", fullName, n, maxLabelNameLength))
+				return
+			}
+		}
+	}
+
+	// Visibility is computed, so removing it from the configuration does not
+	// show up as a change by itself. Mark it unknown when it was configured
+	// before, which makes Update clear it.
+	if !req.State.Raw.IsNull() {
+		configured, diags := req.Private.GetKey(ctx, labelConfiguredKey)
+		resp.Diagnostics.Append(diags...)
+		var prior labelVisibilityConfigured
+		if len(configured) > 0 {
+			if err := json.Unmarshal(configured, &prior); err != nil {
+				resp.Diagnostics.AddError("Failed to read private state", err.Error())
+				return
+			}
+		}
+		var config LabelResourceModel
+		resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+		if prior.LabelListVisibility && config.LabelListVisibility.IsNull() {
+			plan.LabelListVisibility = types.StringUnknown()
+		}
+		if prior.MessageListVisibility && config.MessageListVisibility.IsNull() {
+			plan.MessageListVisibility = types.StringUnknown()
+		}
+	}
+
+	// Resolve palette names so the plan shows the actual colors.
+	if !plan.Color.IsNull() && !plan.Color.IsUnknown() && !plan.Name.IsUnknown() {
+		var color LabelColorModel
+		resp.Diagnostics.Append(plan.Color.As(ctx, &color, basetypes.ObjectAsOptions{})...)
+		if !color.Background.IsUnknown() && !color.Text.IsUnknown() && !color.Auto.IsUnknown() {
+			if resolved := expandLabelColor(ctx, plan, &resp.Diagnostics); resolved != nil {
+				color.BackgroundHex = types.StringValue(resolved.BackgroundColor)
+				color.TextHex = types.StringValue(resolved.TextColor)
+				plan.Color = labelColorObject(ctx, color, &resp.Diagnostics)
+			}
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+func (r *LabelResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data LabelResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Reserved names only clash with system labels at the top level.
+	if data.ParentID.IsNull() && !data.Name.IsUnknown() && isReservedLabelName(data.Name.ValueString()) {
+		resp.Diagnostics.AddAttributeError(path.Root("name"), "Invalid label name",
+			fmt.Sprintf("%q is reserved for a Gmail system label", data.Name.ValueString()))
+	}
+
+	if data.BackgroundColor.IsNull() != data.TextColor.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("background_color"), "Incomplete label color",
+			"background_color and text_color must be set together, otherwise Gmail ignores the color. Use the color attribute to only set a background.")
+	}
+
+	if data.Color.IsNull() || data.Color.IsUnknown() {
+		return
+	}
+	if !data.BackgroundColor.IsNull() || !data.TextColor.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("color"), "Conflicting label colors",
+			"color cannot be combined with background_color or text_color")
+	}
+
+	var color LabelColorModel
+	resp.Diagnostics.Append(data.Color.As(ctx, &color, basetypes.ObjectAsOptions{})...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if color.Auto.ValueBool() && !color.Background.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("color").AtName("auto"), "Conflicting label colors",
+			"auto picks the background color, so background cannot be set as well")
+	}
+	if !color.Auto.ValueBool() && color.Background.IsNull() && !color.Auto.IsUnknown() && !color.Background.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(path.Root("color").AtName("background"), "Missing label color",
+			"Either background or auto must be set")
+	}
+}
+
+// parentName returns the full name of the configured parent label, or an
+// empty string when the label is not nested.
+func (r *LabelResource) parentName(data LabelResourceModel) (string, error) {
+	if data.ParentID.IsNull() || data.ParentID.ValueString() == "" {
+		return "", nil
+	}
+	parent, err := r.config.gmailService.Users.Labels.Get(gmailUser, data.ParentID.ValueString()).Do()
+	if err != nil {
+		return "", err
+	}
+	return parent.Name, nil
+}
+
+// labelPatch returns a patch with only the fields of current that differ
+// from the plan, or nil when nothing needs to change. With clearVisibility
+// set, visibility planned as unknown was removed from the configuration and
+// is cleared, and with clearColor set so is the color when none is
+// configured. Otherwise only the configured fields are patched.
+func labelPatch(ctx context.Context, data LabelResourceModel, fullName string, current *gmail.Label, clearVisibility, clearColor bool, diags *diag.Diagnostics) *gmail.Label {
+	patch := &gmail.Label{}
+	changed := false
+
+	if fullName != current.Name {
+		patch.Name = fullName
+		changed = true
+	}
+
+	switch v := data.LabelListVisibility; {
+	case v.IsUnknown() && clearVisibility:
+		if current.LabelListVisibility != "" {
+			patch.NullFields = append(patch.NullFields, "LabelListVisibility")
+			changed = true
+		}
+	case !v.IsNull() && !v.IsUnknown() && v.ValueString() != current.LabelListVisibility:
+		patch.LabelListVisibility = v.ValueString()
+		changed = true
+	}
+
+	switch v := data.MessageListVisibility; {
+	case v.IsUnknown() && clearVisibility:
+		if current.MessageListVisibility != "" {
+			patch.NullFields = append(patch.NullFields, "MessageListVisibility")
+			changed = true
+		}
+	case !v.IsNull() && !v.IsUnknown() && v.ValueString() != current.MessageListVisibility:
+		patch.MessageListVisibility = v.ValueString()
+		changed = true
+	}
+
+	color := expandLabelColor(ctx, data, diags)
+	switch {
+	case color == nil && current.Color != nil && clearColor:
+		patch.NullFields = append(patch.NullFields, "Color")
+		changed = true
+	case color != nil && (current.Color == nil ||
+		!labelColorsEqual(color.BackgroundColor, current.Color.BackgroundColor) ||
+		!labelColorsEqual(color.TextColor, current.Color.TextColor)):
+		patch.Color = color
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return patch
+}
+
+// labelConfiguredKey is the private state key that records which visibility
+// attributes were set in the configuration at the last apply.
+const labelConfiguredKey = "configured_visibility"
+
+type labelVisibilityConfigured struct {
+	LabelListVisibility   bool `json:"label_list_visibility"`
+	MessageListVisibility bool `json:"message_list_visibility"`
+}
+
+func configuredLabelVisibility(ctx context.Context, config tfsdk.Config, diags *diag.Diagnostics) []byte {
+	var data LabelResourceModel
+	diags.Append(config.Get(ctx, &data)...)
+	b, _ := json.Marshal(labelVisibilityConfigured{
+		LabelListVisibility:   !data.LabelListVisibility.IsNull(),
+		MessageListVisibility: !data.MessageListVisibility.IsNull(),
+	})
+	return b
+}
+
+// labelUnmanagedColorKey is the private state key that marks the color of a
+// label adopted without a configured color. Such a color belongs to the
+// mailbox owner: it is neither reported nor cleared until the configuration
+// sets one.
+const labelUnmanagedColorKey = "unmanaged_color"
+
+// privateStateGetter is implemented by the private state of every request.
+type privateStateGetter interface {
+	GetKey(ctx context.Context, key string) ([]byte, diag.Diagnostics)
+}
+
+func labelColorUnmanaged(ctx context.Context, private privateStateGetter, diags *diag.Diagnostics) bool {
+	value, d := private.GetKey(ctx, labelUnmanagedColorKey)
+	diags.Append(d...)
+	return string(value) == "true"
+}
+
+// unmanagedLabelColorValue returns the private state value for
+// labelUnmanagedColorKey. Nil removes the key.
+func unmanagedLabelColorValue(unmanaged bool) []byte {
+	if unmanaged {
+		return []byte("true")
+	}
+	return nil
+}
+
+const (
+	onDeleteFiltersError  = "ERROR"
+	onDeleteFiltersDelete = "DELETE"
+	onDeleteFiltersDetach = "DETACH"
+)
+
+// referencingFilters returns the filters that add or remove label. With
+// mode ERROR, or any other mode that does not clean them up, an error is
+// reported instead when there are any.
+func (r *LabelResource) referencingFilters(label *gmail.Label, mode string, diags *diag.Diagnostics) []*gmail.Filter {
+	filters, err := listFilters(r.config.gmailService)
+	if err != nil {
+		diags.AddError("Failed to list filters", err.Error())
+		return nil
+	}
+
+	var referencing []*gmail.Filter
+	var ids []string
+	for _, f := range filters {
+		if filterReferencesLabel(f, label.Id) {
+			referencing = append(referencing, f)
+			ids = append(ids, f.Id)
+		}
+	}
+	if len(referencing) > 0 && mode != onDeleteFiltersDelete && mode != onDeleteFiltersDetach {
+		diags.AddError("Label is used by filters",
+			fmt.Sprintf("Label %q is added or removed by the following filters: %s. Remove the label from those filters first, or set on_delete_filters to DELETE or DETACH.", label.Name, strings.Join(ids, ", ")))
+		return nil
+	}
+	return referencing
+}
+
+// cleanupReferencingFilters deletes the filters in referencing, or with mode
+// DETACH recreates them without label, before the label is deleted, since
+// Gmail would otherwise silently rewrite them. A replacement is created
+// before the original is deleted, so a failure never loses a filter. On
+// failure the filters that were already handled and those left untouched
+// are reported.
+func (r *LabelResource) cleanupReferencingFilters(label *gmail.Label, referencing []*gmail.Filter, mode string, diags *diag.Diagnostics) {
+	if len(referencing) == 0 {
+		return
+	}
+
+	var done, remaining []string
+	fail := func(i int, summary, detail string) {
+		for _, f := range referencing[i+1:] {
+			remaining = append(remaining, f.Id)
+		}
+		if len(done) > 0 {
+			detail += fmt.Sprintf("\nAlready handled: %s.", strings.Join(done, ", "))
+		}
+		if len(remaining) > 0 {
+			detail += fmt.Sprintf("\nStill referencing label %q: %s.", label.Name, strings.Join(remaining, ", "))
+		}
+		diags.AddError(summary, detail)
+	}
+
+	var recreated, deleted []string
+	for i, f := range referencing {
+		if mode == onDeleteFiltersDetach {
+			detached := &gmail.Filter{
+				Action: &gmail.FilterAction{
+					AddLabelIds:    withoutString(f.Action.AddLabelIds, label.Id),
+					Forward:        f.Action.Forward,
+					RemoveLabelIds: withoutString(f.Action.RemoveLabelIds, label.Id),
+				},
+				Criteria: f.Criteria,
+			}
+			// A filter without any action left is deleted instead.
+			if len(detached.Action.AddLabelIds) > 0 || len(detached.Action.RemoveLabelIds) > 0 || detached.Action.Forward != "" {
+				result, err := r.config.gmailService.Users.Settings.Filters.Create(gmailUser, detached).Do()
+				if err != nil {
+					remaining = append(remaining, f.Id)
+					fail(i, "Failed to recreate filter", fmt.Sprintf("Filter %s: %s", f.Id, err))
+					return
+				}
+				err = r.config.gmailService.Users.Settings.Filters.Delete(gmailUser, f.Id).Do()
+				if err != nil && !isNotFoundError(err) {
+					remaining = append(remaining, f.Id)
+					fail(i, "Failed to delete filter", fmt.Sprintf("Filter %s: %s. Its replacement %s was created and now duplicates it without the label.", f.Id, err, result.Id))
+					return
+				}
+				recreated = append(recreated, fmt.Sprintf("%s -> %s", f.Id, result.Id))
+				done = append(done, fmt.Sprintf("%s (recreated as %s)", f.Id, result.Id))
+				continue
+			}
+		}
+		err := r.config.gmailService.Users.Settings.Filters.Delete(gmailUser, f.Id).Do()
+		if err != nil && !isNotFoundError(err) {
+			remaining = append(remaining, f.Id)
+			fail(i, "Failed to delete filter", fmt.Sprintf("Filter %s: %s", f.Id, err))
+			return
+		}
+		deleted = append(deleted, f.Id)
+		done = append(done, fmt.Sprintf("%s (deleted)", f.Id))
+	}
+
+	if mode == onDeleteFiltersDetach {
+		detail := fmt.Sprintf("Filters referencing label %q were recreated without it: %s.", label.Name, strings.Join(recreated, ", "))
+		if len(deleted) > 0 {
+			detail += fmt.Sprintf(" Filters left without any action were deleted: %s.", strings.Join(deleted, ", "))
+		}
+		diags.AddWarning("Filters recreated without label", detail)
+		return
+	}
+	diags.AddWarning("Filters deleted along with label",
+		fmt.Sprintf("Filters referencing label %q were deleted: %s", label.Name, strings.Join(deleted, ", ")))
+}
+
+// findLabelByName returns the label called name, or nil if there is none.
+// Gmail treats label names case-insensitively, so the comparison does too.
+func (r *LabelResource) findLabelByName(name string) (*gmail.Label, error) {
+	res, err := r.config.gmailService.Users.Labels.List(gmailUser).Do()
+	if err != nil {
+		return nil, err
+	}
+	for _, label := range res.Labels {
+		if strings.EqualFold(label.Name, name) {
+			return label, nil
+		}
+	}
+	return nil, nil
+}
+
+// renameChildren moves every label nested below oldName to the same place
+// below newName, and returns the previous names of the labels it moved. The
+// provider cannot tell which of them other resources manage, so the caller
+// warns about them.
+func (r *LabelResource) renameChildren(oldName, newName string) ([]string, error) {
+	res, err := r.config.gmailService.Users.Labels.List(gmailUser).Do()
+	if err != nil {
+		return nil, err
+	}
+	var renamed []string
+	for _, label := range res.Labels {
+		if !strings.HasPrefix(label.Name, oldName+"/") {
+			continue
+		}
+		patch := &gmail.Label{Name: newName + strings.TrimPrefix(label.Name, oldName)}
+		if _, err := r.config.gmailService.Users.Labels.Patch(gmailUser, label.Id, patch).Do(); err != nil {
+			return renamed, fmt.Errorf("renaming %q: %w", label.Name, err)
+		}
+		renamed = append(renamed, strconv.Quote(label.Name))
+	}
+	return renamed, nil
+}
+
+func (r *LabelResource) updateModelFromAPIResponse(ctx context.Context, data *LabelResourceModel, label *gmail.Label, parentName string, diags *diag.Diagnostics) {
 	data.Name = types.StringValue(label.Name)
+	if parentName != "" && strings.HasPrefix(label.Name, parentName+"/") {
+		data.Name = types.StringValue(strings.TrimPrefix(label.Name, parentName+"/"))
+	}
+	setLabelPath(ctx, data, label.Name, diags)
 	data.LabelListVisibility = types.StringValue(label.LabelListVisibility)
 	data.MessageListVisibility = types.StringValue(label.MessageListVisibility)
-	data.MessagesTotal = types.Int64Value(label.MessagesTotal)
-	data.MessagesUnread = types.Int64Value(label.MessagesUnread)
-	data.ThreadsTotal = types.Int64Value(label.ThreadsTotal)
-	data.ThreadsUnread = types.Int64Value(label.ThreadsUnread)
 	data.Type = types.StringValue(label.Type)
 
-	if label.Color != nil {
-		data.BackgroundColor = types.StringValue(label.Color.BackgroundColor)
-		data.TextColor = types.StringValue(label.Color.TextColor)
-	} else {
+	updateLabelColorFromAPIResponse(ctx, data, label.Color, diags)
+}
+
+// updateLabelColorFromAPIResponse stores the label color in whichever form
+// the configuration uses. Configured values are kept as long as they resolve
+// to the color Gmail reports, so palette names and differently cased hex
+// strings do not show up as a diff.
+func updateLabelColorFromAPIResponse(ctx context.Context, data *LabelResourceModel, color *gmail.LabelColor, diags *diag.Diagnostics) {
+	if !data.Color.IsNull() {
+		expected := expandLabelColor(ctx, *data, diags)
+		if color == nil {
+			data.Color = types.ObjectNull(labelColorAttrTypes)
+			return
+		}
+
+		var model LabelColorModel
+		diags.Append(data.Color.As(ctx, &model, basetypes.ObjectAsOptions{})...)
+		if expected == nil || !labelColorsEqual(expected.BackgroundColor, color.BackgroundColor) || !labelColorsEqual(expected.TextColor, color.TextColor) {
+			model = LabelColorModel{
+				Background: types.StringValue(color.BackgroundColor),
+				Text:       types.StringValue(color.TextColor),
+				Auto:       types.BoolNull(),
+			}
+		}
+		model.BackgroundHex = types.StringValue(color.BackgroundColor)
+		model.TextHex = types.StringValue(color.TextColor)
+		data.Color = labelColorObject(ctx, model, diags)
+		return
+	}
+
+	if color == nil {
 		data.BackgroundColor = types.StringNull()
 		data.TextColor = types.StringNull()
+		return
+	}
+	if bg, err := resolveLabelColor(data.BackgroundColor.ValueString()); err != nil || !labelColorsEqual(bg, color.BackgroundColor) {
+		data.BackgroundColor = types.StringValue(color.BackgroundColor)
 	}
+	if text, err := resolveLabelColor(data.TextColor.ValueString()); err != nil || !labelColorsEqual(text, color.TextColor) {
+		data.TextColor = types.StringValue(color.TextColor)
+	}
+}
+
+// expandLabelColor returns the color configured for the label, or nil when
+// the label should not have a color.
+func expandLabelColor(ctx context.Context, data LabelResourceModel, diags *diag.Diagnostics) *gmail.LabelColor {
+	if data.Color.IsNull() {
+		if data.BackgroundColor.IsNull() || data.TextColor.IsNull() {
+			return nil
+		}
+		bg, err := resolveLabelColor(data.BackgroundColor.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root("background_color"), "Invalid label color", err.Error())
+			return nil
+		}
+		text, err := resolveLabelColor(data.TextColor.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root("text_color"), "Invalid label color", err.Error())
+			return nil
+		}
+		return &gmail.LabelColor{BackgroundColor: bg, TextColor: text}
+	}
+
+	var color LabelColorModel
+	diags.Append(data.Color.As(ctx, &color, basetypes.ObjectAsOptions{})...)
+	if diags.HasError() {
+		return nil
+	}
+
+	var bg string
+	if color.Auto.ValueBool() {
+		bg = autoLabelColor(data.Name.ValueString())
+	} else {
+		var err error
+		if bg, err = resolveLabelColor(color.Background.ValueString()); err != nil {
+			diags.AddAttributeError(path.Root("color").AtName("background"), "Invalid label color", err.Error())
+			return nil
+		}
+	}
+
+	text := contrastingLabelTextColor(bg)
+	if !color.Text.IsNull() {
+		var err error
+		if text, err = resolveLabelColor(color.Text.ValueString()); err != nil {
+			diags.AddAttributeError(path.Root("color").AtName("text"), "Invalid label color", err.Error())
+			return nil
+		}
+	}
+	return &gmail.LabelColor{BackgroundColor: bg, TextColor: text}
+}
+
+func labelColorObject(ctx context.Context, color LabelColorModel, diags *diag.Diagnostics) types.Object {
+	obj, d := types.ObjectValueFrom(ctx, labelColorAttrTypes, color)
+	diags.Append(d...)
+	return obj
+}
+
+// labelPath returns the full name of a label called name nested below
+// parentName.
+func labelPath(parentName, name string) string {
+	if parentName == "" {
+		return name
+	}
+	return parentName + "/" + name
+}
+
+func setLabelPath(ctx context.Context, data *LabelResourceModel, fullName string, diags *diag.Diagnostics) {
+	parts := strings.Split(fullName, "/")
+	data.Path = types.StringValue(fullName)
+	var d diag.Diagnostics
+	data.PathParts, d = types.ListValueFrom(ctx, types.StringType, parts)
+	diags.Append(d...)
+	data.Depth = types.Int64Value(int64(len(parts) - 1))
 }
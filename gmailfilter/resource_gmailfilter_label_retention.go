@@ -0,0 +1,296 @@
+package gmailfilter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+var _ resource.Resource = &LabelRetentionResource{}
+var _ resource.ResourceWithImportState = &LabelRetentionResource{}
+var _ resource.ResourceWithModifyPlan = &LabelRetentionResource{}
+
+// Values of the mode attribute of gmailfilter_label_retention.
+const (
+	retentionModeArchive     = "archive"
+	retentionModeTrash       = "trash"
+	retentionModeRemoveLabel = "remove_label"
+)
+
+func NewLabelRetentionResource() resource.Resource {
+	return &LabelRetentionResource{}
+}
+
+type LabelRetentionResource struct {
+	config *Config
+}
+
+type LabelRetentionResourceModel struct {
+	ID                  types.String `tfsdk:"id"`
+	LabelID             types.String `tfsdk:"label_id"`
+	MaxAge              types.String `tfsdk:"max_age"`
+	Mode                types.String `tfsdk:"mode"`
+	EnforceOnRefresh    types.Bool   `tfsdk:"enforce_on_refresh"`
+	LastProcessedCount  types.Int64  `tfsdk:"last_processed_count"`
+	TotalProcessedCount types.Int64  `tfsdk:"total_processed_count"`
+}
+
+func (r *LabelRetentionResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_label_retention"
+}
+
+func (r *LabelRetentionResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Enforces a maximum age for the messages with a Gmail label. Every apply archives, trashes or unlabels the messages that are older, and so does every refresh when enforce_on_refresh is set. Planning checks whether any messages have expired and, if so, shows the processed counts as changing. Destroying this resource stops enforcement and leaves the messages as they are.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of the label",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"label_id": schema.StringAttribute{
+				Required:    true,
+				Description: "The ID of the label whose messages expire. Changing this will require the resource to be recreated.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"max_age": schema.StringAttribute{
+				Required:    true,
+				Description: "How old messages may get, as for Gmail's older_than search operator: a number followed by d (days), m (months) or y (years), e.g. 90d",
+				Validators: []validator.String{
+					maxAgeValidator{},
+				},
+			},
+			"mode": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(retentionModeArchive),
+				Description: "What happens to older messages: archive removes them from the inbox, trash moves them to the trash, remove_label removes the label. Defaults to archive.",
+				Validators: []validator.String{
+					stringOneOf(retentionModeArchive, retentionModeTrash, retentionModeRemoveLabel),
+				},
+			},
+			"enforce_on_refresh": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether to also process older messages whenever the resource is refreshed, including during terraform plan",
+			},
+			"last_processed_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of messages processed by the latest run",
+			},
+			"total_processed_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of messages processed by all runs since the resource was created",
+			},
+		},
+	}
+}
+
+func (r *LabelRetentionResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Resource Configure Type", "Expected *Config")
+		return
+	}
+	r.config = config
+}
+
+func (r *LabelRetentionResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data LabelRetentionResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if _, err := r.config.gmailService.Users.Labels.Get(gmailUser, data.LabelID.ValueString()).Do(); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("label_id"), "Failed to read label", err.Error())
+		return
+	}
+
+	data.ID = data.LabelID
+	data.TotalProcessedCount = types.Int64Value(0)
+	err := r.enforce(ctx, &data)
+
+	// The counts include the batches processed before a failure, so state is
+	// saved either way.
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to enforce label retention", err.Error())
+	}
+}
+
+func (r *LabelRetentionResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data LabelRetentionResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	_, err := r.config.gmailService.Users.Labels.Get(gmailUser, data.ID.ValueString()).Do()
+	if err != nil {
+		if isNotFoundError(err) {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		resp.Diagnostics.AddError("Failed to read label", err.Error())
+		return
+	}
+
+	// Imported resources only know their ID.
+	data.LabelID = data.ID
+	if data.Mode.IsNull() {
+		data.Mode = types.StringValue(retentionModeArchive)
+	}
+	if data.EnforceOnRefresh.IsNull() {
+		data.EnforceOnRefresh = types.BoolValue(false)
+	}
+	if data.LastProcessedCount.IsNull() {
+		data.LastProcessedCount = types.Int64Value(0)
+	}
+	if data.TotalProcessedCount.IsNull() {
+		data.TotalProcessedCount = types.Int64Value(0)
+	}
+
+	if data.EnforceOnRefresh.ValueBool() && !data.MaxAge.IsNull() {
+		err = r.enforce(ctx, &data)
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to enforce label retention", err.Error())
+	}
+}
+
+func (r *LabelRetentionResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state LabelRetentionResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.TotalProcessedCount = state.TotalProcessedCount
+	err := r.enforce(ctx, &plan)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to enforce label retention", err.Error())
+	}
+}
+
+func (r *LabelRetentionResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// Nothing exists in Gmail for this resource; destroying it only stops
+	// enforcement.
+}
+
+// ModifyPlan marks the counts unknown when messages have expired since the
+// last run, so that the apply updates the resource and processes them. A
+// plan without expired messages and configuration changes shows no diff.
+func (r *LabelRetentionResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || req.State.Raw.IsNull() {
+		return
+	}
+
+	var plan, state LabelRetentionResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if r.config != nil && plan.MaxAge.Equal(state.MaxAge) && plan.Mode.Equal(state.Mode) && plan.LabelID.Equal(state.LabelID) {
+		query, labelIDs, _, _, err := retentionSearch(plan)
+		if err == nil {
+			var ids []string
+			ids, err = searchMessageIDs(ctx, r.config.gmailService, query, labelIDs, 1)
+			if err == nil && len(ids) == 0 {
+				return
+			}
+		}
+		if err != nil {
+			tflog.Warn(ctx, "Failed to check for expired messages", map[string]any{"error": err.Error()})
+		}
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("last_processed_count"), types.Int64Unknown())...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("total_processed_count"), types.Int64Unknown())...)
+}
+
+func (r *LabelRetentionResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+// retentionSearch returns the search that finds the expired messages of
+// data's label, and the labels to add to and remove from them.
+func retentionSearch(data LabelRetentionResourceModel) (query string, labelIDs, add, remove []string, err error) {
+	labelID := data.LabelID.ValueString()
+	query = "older_than:" + data.MaxAge.ValueString()
+	labelIDs = []string{labelID}
+	switch data.Mode.ValueString() {
+	case retentionModeArchive:
+		labelIDs = append(labelIDs, "INBOX")
+		remove = []string{"INBOX"}
+	case retentionModeTrash:
+		add = []string{"TRASH"}
+	case retentionModeRemoveLabel:
+		remove = []string{labelID}
+	default:
+		return "", nil, nil, nil, fmt.Errorf("unknown mode %q", data.Mode.ValueString())
+	}
+	return query, labelIDs, add, remove, nil
+}
+
+// enforce processes the messages in data's label that are older than its
+// max_age, and records the count in data. On failure the count covers the
+// batches that were processed before it.
+func (r *LabelRetentionResource) enforce(ctx context.Context, data *LabelRetentionResourceModel) error {
+	processed := 0
+	defer func() {
+		data.LastProcessedCount = types.Int64Value(int64(processed))
+		data.TotalProcessedCount = types.Int64Value(data.TotalProcessedCount.ValueInt64() + int64(processed))
+	}()
+
+	labelID := data.LabelID.ValueString()
+	query, labelIDs, add, remove, err := retentionSearch(*data)
+	if err != nil {
+		return err
+	}
+
+	ids, err := searchMessageIDs(ctx, r.config.gmailService, query, labelIDs, 0)
+	if err != nil {
+		return fmt.Errorf("searching for %q in label %s: %w", query, labelID, err)
+	}
+	tflog.Info(ctx, "Enforcing label retention", map[string]any{
+		"label_id": labelID,
+		"query":    query,
+		"mode":     data.Mode.ValueString(),
+		"messages": len(ids),
+	})
+
+	return batchModifyMessages(ctx, r.config.gmailService, ids, add, remove, func(done int) {
+		processed = done
+		tflog.Info(ctx, "Enforced label retention", map[string]any{
+			"label_id": labelID,
+			"done":     done,
+			"total":    len(ids),
+		})
+	})
+}
@@ -0,0 +1,197 @@
+package gmailfilter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// fakeLabelsServer serves the Gmail labels API from labels, applying
+// patches to them.
+func fakeLabelsServer(t *testing.T, labels map[string]*gmail.Label) *gmail.Service {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, _ := strings.CutPrefix(req.URL.Path, "/gmail/v1/users/me/labels")
+		id = strings.TrimPrefix(id, "/")
+		switch {
+		case req.Method == http.MethodGet && id == "":
+			res := &gmail.ListLabelsResponse{}
+			for _, label := range labels {
+				res.Labels = append(res.Labels, label)
+			}
+			json.NewEncoder(w).Encode(res)
+		case req.Method == http.MethodGet && labels[id] != nil:
+			json.NewEncoder(w).Encode(labels[id])
+		case req.Method == http.MethodPatch && labels[id] != nil:
+			var fields map[string]json.RawMessage
+			if err := json.NewDecoder(req.Body).Decode(&fields); err != nil {
+				t.Errorf("decoding patch: %s", err)
+			}
+			label := labels[id]
+			for name, value := range fields {
+				switch name {
+				case "name":
+					json.Unmarshal(value, &label.Name)
+				case "labelListVisibility":
+					json.Unmarshal(value, &label.LabelListVisibility)
+				case "messageListVisibility":
+					json.Unmarshal(value, &label.MessageListVisibility)
+				case "color":
+					label.Color = nil
+					json.Unmarshal(value, &label.Color)
+				}
+			}
+			json.NewEncoder(w).Encode(label)
+		default:
+			http.Error(w, `{"error": {"code": 404, "message": "Not Found"}}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	svc, err := gmail.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+// newPrivateState returns empty private state of the type the framework
+// passes to resources.
+func newPrivateState[T any](*T) *T {
+	return new(T)
+}
+
+func TestLabelAdoptKeepsUnconfiguredColor(t *testing.T) {
+	ctx := context.Background()
+	color := &gmail.LabelColor{BackgroundColor: "#fb4c2f", TextColor: "#ffffff"}
+	labels := map[string]*gmail.Label{
+		"Label_1": {Id: "Label_1", Name: "Work", Type: "user", Color: color, LabelListVisibility: "labelShow", MessageListVisibility: "show"},
+	}
+	r := &LabelResource{config: &Config{gmailService: fakeLabelsServer(t, labels)}}
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	s := schemaResp.Schema
+
+	config := LabelResourceModel{
+		ID:                    types.StringNull(),
+		Name:                  types.StringValue("Work"),
+		ParentID:              types.StringNull(),
+		Path:                  types.StringNull(),
+		PathParts:             types.ListNull(types.StringType),
+		Depth:                 types.Int64Null(),
+		Color:                 types.ObjectNull(labelColorAttrTypes),
+		BackgroundColor:       types.StringNull(),
+		TextColor:             types.StringNull(),
+		LabelListVisibility:   types.StringNull(),
+		MessageListVisibility: types.StringNull(),
+		Type:                  types.StringNull(),
+		DeletionPolicy:        types.StringNull(),
+		ForceDestroy:          types.BoolNull(),
+		OnDeleteRelabelTo:     types.StringNull(),
+		AdoptExisting:         types.BoolValue(true),
+		OnDeleteFilters:       types.StringNull(),
+	}
+	plan := config
+	plan.ID = types.StringUnknown()
+	plan.Path = types.StringValue("Work")
+	plan.PathParts = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("Work")})
+	plan.Depth = types.Int64Value(0)
+	plan.LabelListVisibility = types.StringUnknown()
+	plan.MessageListVisibility = types.StringUnknown()
+	plan.Type = types.StringUnknown()
+	plan.DeletionPolicy = types.StringValue(deletionPolicyDelete)
+	plan.ForceDestroy = types.BoolValue(false)
+	plan.OnDeleteFilters = types.StringValue(onDeleteFiltersError)
+
+	// Adopt the label without configuring a color.
+	createReq := resource.CreateRequest{
+		Config: tfsdk.Config{Schema: s, Raw: setPlan(t, ctx, s, config).Raw},
+		Plan:   setPlan(t, ctx, s, plan),
+	}
+	createResp := resource.CreateResponse{State: tfsdk.State{Schema: s}}
+	createResp.Private = newPrivateState(createResp.Private)
+	r.Create(ctx, createReq, &createResp)
+	if createResp.Diagnostics.HasError() {
+		t.Fatalf("Create: %v", createResp.Diagnostics)
+	}
+
+	// Refresh it; the color is not reported as a diff.
+	readReq := resource.ReadRequest{State: createResp.State, Private: createResp.Private}
+	readResp := resource.ReadResponse{State: createResp.State, Private: createResp.Private}
+	r.Read(ctx, readReq, &readResp)
+	if readResp.Diagnostics.HasError() {
+		t.Fatalf("Read: %v", readResp.Diagnostics)
+	}
+	var state LabelResourceModel
+	readResp.State.Get(ctx, &state)
+	if !state.BackgroundColor.IsNull() || !state.TextColor.IsNull() {
+		t.Fatalf("refreshed color = %s/%s, want null", state.BackgroundColor, state.TextColor)
+	}
+
+	// Change something else; the color survives.
+	config.LabelListVisibility = types.StringValue("labelHide")
+	plan = state
+	plan.LabelListVisibility = config.LabelListVisibility
+	updateReq := resource.UpdateRequest{
+		Config:  tfsdk.Config{Schema: s, Raw: setPlan(t, ctx, s, config).Raw},
+		Plan:    setPlan(t, ctx, s, plan),
+		State:   readResp.State,
+		Private: readResp.Private,
+	}
+	updateResp := resource.UpdateResponse{State: readResp.State, Private: readResp.Private}
+	r.Update(ctx, updateReq, &updateResp)
+	if updateResp.Diagnostics.HasError() {
+		t.Fatalf("Update: %v", updateResp.Diagnostics)
+	}
+	if got := labels["Label_1"]; got.Color == nil || got.Color.BackgroundColor != color.BackgroundColor || got.LabelListVisibility != "labelHide" {
+		t.Errorf("label after update = %+v, color %+v", got, got.Color)
+	}
+
+	// Configuring a color takes it over, and removing it again clears it.
+	updateResp.State.Get(ctx, &state)
+	for _, bg := range []string{"#16a765", ""} {
+		plan = state
+		plan.BackgroundColor, plan.TextColor = types.StringNull(), types.StringNull()
+		if bg != "" {
+			plan.BackgroundColor, plan.TextColor = types.StringValue(bg), types.StringValue("#000000")
+		}
+		config.BackgroundColor, config.TextColor = plan.BackgroundColor, plan.TextColor
+		updateReq = resource.UpdateRequest{
+			Config:  tfsdk.Config{Schema: s, Raw: setPlan(t, ctx, s, config).Raw},
+			Plan:    setPlan(t, ctx, s, plan),
+			State:   updateResp.State,
+			Private: updateResp.Private,
+		}
+		updateResp = resource.UpdateResponse{State: updateResp.State, Private: updateResp.Private}
+		r.Update(ctx, updateReq, &updateResp)
+		if updateResp.Diagnostics.HasError() {
+			t.Fatalf("Update with background %q: %v", bg, updateResp.Diagnostics)
+		}
+		got := labels["Label_1"].Color
+		if bg == "" && got != nil || bg != "" && (got == nil || got.BackgroundColor != bg) {
+			t.Errorf("color after update with background %q = %+v", bg, got)
+		}
+		updateResp.State.Get(ctx, &state)
+	}
+}
+
+func setPlan(t *testing.T, ctx context.Context, s schema.Schema, data LabelResourceModel) tfsdk.Plan {
+	t.Helper()
+	plan := tfsdk.Plan{Schema: s}
+	if diags := plan.Set(ctx, &data); diags.HasError() {
+		t.Fatalf("setting plan: %v", diags)
+	}
+	return plan
+}
@@ -0,0 +1,637 @@
+package gmailfilter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/gmail/v1"
+)
+
+var _ resource.Resource = &LabelsResource{}
+var _ resource.ResourceWithImportState = &LabelsResource{}
+var _ resource.ResourceWithValidateConfig = &LabelsResource{}
+
+func NewLabelsResource() resource.Resource {
+	return &LabelsResource{}
+}
+
+type LabelsResource struct {
+	config *Config
+}
+
+type LabelsResourceModel struct {
+	ID          types.String `tfsdk:"id"`
+	NamePrefix  types.String `tfsdk:"name_prefix"`
+	ForceDelete types.Bool   `tfsdk:"force_delete"`
+	Labels      types.Map    `tfsdk:"labels"`
+}
+
+type LabelsEntryModel struct {
+	ID                    types.String `tfsdk:"id"`
+	Name                  types.String `tfsdk:"name"`
+	BackgroundColor       types.String `tfsdk:"background_color"`
+	TextColor             types.String `tfsdk:"text_color"`
+	LabelListVisibility   types.String `tfsdk:"label_list_visibility"`
+	MessageListVisibility types.String `tfsdk:"message_list_visibility"`
+}
+
+var labelsEntryAttrTypes = map[string]attr.Type{
+	"id":                      types.StringType,
+	"name":                    types.StringType,
+	"background_color":        types.StringType,
+	"text_color":              types.StringType,
+	"label_list_visibility":   types.StringType,
+	"message_list_visibility": types.StringType,
+}
+
+func (r *LabelsResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_labels"
+}
+
+func (r *LabelsResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages the complete set of user labels for the mailbox, optionally limited to the labels under a parent label. User labels that are not configured are deleted.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The mailbox the labels belong to",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"name_prefix": schema.StringAttribute{
+				Optional:    true,
+				Description: "Only manage the user label with this name and the labels nested under it, e.g. Work manages Work and Work/Clients but not Workshop. Changing this will require the resource to be recreated.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"force_delete": schema.BoolAttribute{
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+				Description: "Whether labels that still contain messages may be deleted",
+			},
+			"labels": schema.MapNestedAttribute{
+				Required:    true,
+				Description: "The labels that should exist, keyed by an arbitrary stable key. Changing the name of an entry renames the label.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The immutable ID of the label",
+							PlanModifiers: []planmodifier.String{
+								stringplanmodifier.UseStateForUnknown(),
+							},
+						},
+						"name": schema.StringAttribute{
+							Required:    true,
+							Description: "The display name of the label",
+							Validators: []validator.String{
+								labelNameValidator{rejectReserved: true},
+							},
+						},
+						"background_color": schema.StringAttribute{
+							Optional:    true,
+							Description: "The background color, as hex string or palette name",
+							Validators: []validator.String{
+								labelColorValidator{},
+							},
+						},
+						"text_color": schema.StringAttribute{
+							Optional:    true,
+							Description: "The text color, as hex string or palette name",
+							Validators: []validator.String{
+								labelColorValidator{},
+							},
+						},
+						"label_list_visibility": schema.StringAttribute{
+							Optional:    true,
+							Computed:    true,
+							Description: "The visibility of the label in the label list in the Gmail web interface (labelShow/labelShowIfUnread/labelHide)",
+							PlanModifiers: []planmodifier.String{
+								stringplanmodifier.UseStateForUnknown(),
+							},
+							Validators: []validator.String{
+								stringOneOf(labelListVisibilityValues...),
+							},
+						},
+						"message_list_visibility": schema.StringAttribute{
+							Optional:    true,
+							Computed:    true,
+							Description: "The visibility of messages with this label in the message list (show/hide)",
+							PlanModifiers: []planmodifier.String{
+								stringplanmodifier.UseStateForUnknown(),
+							},
+							Validators: []validator.String{
+								stringOneOf(messageListVisibilityValues...),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *LabelsResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Resource Configure Type", "Expected *Config")
+		return
+	}
+	r.config = config
+}
+
+func (r *LabelsResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data LabelsResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// State is saved even when apply fails part way, so that the labels it
+	// created are tracked.
+	r.apply(ctx, &data, &resp.Diagnostics)
+	data.ID = types.StringValue(gmailUser)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *LabelsResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data LabelsResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	existing, err := r.listUserLabels(data.NamePrefix.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list labels", err.Error())
+		return
+	}
+
+	// A null map means the resource was just imported, in which case every
+	// matching label is taken over and keyed by its name.
+	entries := map[string]LabelsEntryModel{}
+	if !data.Labels.IsNull() {
+		resp.Diagnostics.Append(data.Labels.ElementsAs(ctx, &entries, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	if data.ForceDelete.IsNull() {
+		data.ForceDelete = types.BoolValue(false)
+	}
+
+	byID := map[string]*gmail.Label{}
+	for _, label := range existing {
+		byID[label.Id] = label
+	}
+
+	seen := map[string]bool{}
+	for key, entry := range entries {
+		label, ok := byID[entry.ID.ValueString()]
+		if !ok {
+			delete(entries, key)
+			continue
+		}
+		entries[key] = flattenLabelsEntry(label, entry)
+		seen[label.Id] = true
+	}
+
+	// Report labels that are not managed, so the plan shows their deletion.
+	for _, label := range existing {
+		if seen[label.Id] {
+			continue
+		}
+		key := label.Name
+		if _, ok := entries[key]; ok {
+			key = fmt.Sprintf("%s (%s)", label.Name, label.Id)
+		}
+		entries[key] = flattenLabelsEntry(label, LabelsEntryModel{})
+	}
+
+	labels, diags := types.MapValueFrom(ctx, types.ObjectType{AttrTypes: labelsEntryAttrTypes}, entries)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Labels = labels
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *LabelsResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state LabelsResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	r.apply(ctx, &plan, &resp.Diagnostics)
+	plan.ID = state.ID
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *LabelsResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data LabelsResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var entries map[string]LabelsEntryModel
+	resp.Diagnostics.Append(data.Labels.ElementsAs(ctx, &entries, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	existing, err := r.listUserLabels(data.NamePrefix.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to list labels", err.Error())
+		return
+	}
+	managed := map[string]bool{}
+	for _, entry := range entries {
+		managed[entry.ID.ValueString()] = true
+	}
+	var labels []*gmail.Label
+	for _, label := range existing {
+		if managed[label.Id] {
+			labels = append(labels, label)
+		}
+	}
+
+	r.deleteLabels(labels, data.ForceDelete.ValueBool(), &resp.Diagnostics)
+}
+
+func (r *LabelsResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data LabelsResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() || data.Labels.IsNull() || data.Labels.IsUnknown() {
+		return
+	}
+
+	var entries map[string]LabelsEntryModel
+	resp.Diagnostics.Append(data.Labels.ElementsAs(ctx, &entries, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	prefix := data.NamePrefix
+	for key, entry := range entries {
+		entryPath := path.Root("labels").AtMapKey(key)
+		if entry.BackgroundColor.IsNull() != entry.TextColor.IsNull() {
+			resp.Diagnostics.AddAttributeError(entryPath.AtName("background_color"), "Incomplete label color",
+				"background_color and text_color must be set together, otherwise Gmail ignores the color")
+		}
+		if !prefix.IsUnknown() && !entry.Name.IsUnknown() && !labelUnderPrefix(entry.Name.ValueString(), prefix.ValueString()) {
+			resp.Diagnostics.AddAttributeError(entryPath.AtName("name"), "Label outside of name prefix",
+				fmt.Sprintf("Label %q is neither the label %q nor nested under it", entry.Name.ValueString(), prefix.ValueString()))
+		}
+	}
+}
+
+func (r *LabelsResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	// The import ID is the name prefix, or "me" to manage every user label.
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), gmailUser)...)
+	if req.ID != gmailUser {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name_prefix"), req.ID)...)
+	}
+}
+
+// apply reconciles the user labels in the mailbox with the desired labels in
+// data. Entries that already have an ID are renamed and recolored in place,
+// new entries adopt an unmanaged label with the same name or create one, and
+// all remaining labels within the prefix are deleted. The IDs and visibility
+// settings in data are filled in from the API responses. On failure data
+// holds the entries whose labels exist, so that they can be saved to state;
+// the next refresh corrects their other attributes.
+func (r *LabelsResource) apply(ctx context.Context, data *LabelsResourceModel, diags *diag.Diagnostics) {
+	prefix := data.NamePrefix.ValueString()
+
+	var entries map[string]LabelsEntryModel
+	diags.Append(data.Labels.ElementsAs(ctx, &entries, false)...)
+	if diags.HasError() {
+		return
+	}
+	defer func() {
+		data.Labels = knownLabelsEntries(ctx, entries, diags)
+	}()
+
+	existing, err := r.listUserLabels(prefix)
+	if err != nil {
+		diags.AddError("Failed to list labels", err.Error())
+		return
+	}
+	byID := map[string]*gmail.Label{}
+	byName := map[string]*gmail.Label{}
+	for _, label := range existing {
+		byID[label.Id] = label
+		byName[label.Name] = label
+	}
+
+	claimed := map[string]bool{}
+	for _, entry := range entries {
+		if !entry.ID.IsUnknown() && byID[entry.ID.ValueString()] != nil {
+			claimed[entry.ID.ValueString()] = true
+		}
+	}
+
+	r.moveAsideRenamed(entries, byID, byName, diags)
+	if diags.HasError() {
+		return
+	}
+
+	// Process entries in name order so that parents are created before
+	// their children.
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return entries[keys[i]].Name.ValueString() < entries[keys[j]].Name.ValueString()
+	})
+
+	for _, key := range keys {
+		entry := entries[key]
+
+		current := byID[entry.ID.ValueString()]
+		if entry.ID.IsUnknown() || current == nil {
+			current = nil
+			if candidate := byName[entry.Name.ValueString()]; candidate != nil && !claimed[candidate.Id] {
+				current = candidate
+				claimed[candidate.Id] = true
+			}
+		}
+
+		var result *gmail.Label
+		if current == nil {
+			result, err = r.config.gmailService.Users.Labels.Create(gmailUser, expandLabelsEntry(entry)).Do()
+			if err != nil {
+				diags.AddError("Failed to create label", fmt.Sprintf("Label %q: %s", entry.Name.ValueString(), err))
+				return
+			}
+			r.config.forgetLabels()
+		} else {
+			result = current
+			if patch := labelsEntryPatch(entry, current); patch != nil {
+				result, err = r.config.gmailService.Users.Labels.Patch(gmailUser, current.Id, patch).Do()
+				if err != nil {
+					diags.AddError("Failed to update label", fmt.Sprintf("Label %q: %s", entry.Name.ValueString(), err))
+					return
+				}
+			}
+		}
+
+		entry.ID = types.StringValue(result.Id)
+		entry.LabelListVisibility = types.StringValue(result.LabelListVisibility)
+		entry.MessageListVisibility = types.StringValue(result.MessageListVisibility)
+		entries[key] = entry
+		claimed[result.Id] = true
+	}
+
+	var stale []*gmail.Label
+	for _, label := range existing {
+		if !claimed[label.Id] {
+			stale = append(stale, label)
+		}
+	}
+	r.deleteLabels(stale, data.ForceDelete.ValueBool(), diags)
+}
+
+// knownLabelsEntries converts entries into a map value for state. Entries
+// whose label was never created are left out, and visibility that is still
+// unknown is stored as null.
+func knownLabelsEntries(ctx context.Context, entries map[string]LabelsEntryModel, diags *diag.Diagnostics) types.Map {
+	known := map[string]LabelsEntryModel{}
+	for key, entry := range entries {
+		if entry.ID.IsUnknown() || entry.ID.IsNull() {
+			continue
+		}
+		if entry.LabelListVisibility.IsUnknown() {
+			entry.LabelListVisibility = types.StringNull()
+		}
+		if entry.MessageListVisibility.IsUnknown() {
+			entry.MessageListVisibility = types.StringNull()
+		}
+		known[key] = entry
+	}
+	labels, d := types.MapValueFrom(ctx, types.ObjectType{AttrTypes: labelsEntryAttrTypes}, known)
+	diags.Append(d...)
+	return labels
+}
+
+// moveAsideRenamed gives a temporary name to every label that is renamed
+// while another entry wants its current name, so that renames which swap or
+// shift names between labels do not conflict. byID and byName are updated to
+// the temporary names.
+func (r *LabelsResource) moveAsideRenamed(entries map[string]LabelsEntryModel, byID, byName map[string]*gmail.Label, diags *diag.Diagnostics) {
+	wanted := map[string]string{}
+	for _, entry := range entries {
+		if current := byID[entry.ID.ValueString()]; current != nil && !entry.ID.IsUnknown() {
+			wanted[current.Id] = entry.Name.ValueString()
+		}
+	}
+	for _, entry := range entries {
+		occupant := byName[entry.Name.ValueString()]
+		if occupant == nil || occupant.Id == entry.ID.ValueString() {
+			continue
+		}
+		name, renamed := wanted[occupant.Id]
+		if !renamed || name == occupant.Name {
+			continue
+		}
+		temporary := fmt.Sprintf("%s (renaming %s)", occupant.Name, occupant.Id)
+		result, err := r.config.gmailService.Users.Labels.Patch(gmailUser, occupant.Id, &gmail.Label{Name: temporary}).Do()
+		if err != nil {
+			diags.AddError("Failed to update label", fmt.Sprintf("Label %q: %s", occupant.Name, err))
+			return
+		}
+		delete(byName, occupant.Name)
+		byID[result.Id] = result
+		byName[result.Name] = result
+	}
+}
+
+// deleteLabels deletes the given labels, children before their parents.
+// Unless force is set, nothing is deleted if any of the labels still
+// contains messages.
+func (r *LabelsResource) deleteLabels(labels []*gmail.Label, force bool, diags *diag.Diagnostics) {
+	if !force {
+		// Labels.List does not report message counts.
+		var counted []*gmail.Label
+		for _, label := range labels {
+			full, err := r.config.gmailService.Users.Labels.Get(gmailUser, label.Id).Do()
+			if err != nil {
+				if isNotFoundError(err) {
+					continue
+				}
+				diags.AddError("Failed to read label", err.Error())
+				return
+			}
+			counted = append(counted, full)
+		}
+		labels = counted
+
+		var nonEmpty []string
+		for _, label := range labels {
+			if label.MessagesTotal > 0 {
+				nonEmpty = append(nonEmpty, fmt.Sprintf("%s (%d messages)", label.Name, label.MessagesTotal))
+			}
+		}
+		if len(nonEmpty) > 0 {
+			sort.Strings(nonEmpty)
+			diags.AddError("Refusing to delete labels that contain messages",
+				fmt.Sprintf("The following labels still contain messages and force_delete is not set:\n  %s", strings.Join(nonEmpty, "\n  ")))
+			return
+		}
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name > labels[j].Name
+	})
+	for _, label := range labels {
+		err := r.config.gmailService.Users.Labels.Delete(gmailUser, label.Id).Do()
+		if err != nil && !isNotFoundError(err) {
+			diags.AddError("Failed to delete label", fmt.Sprintf("Label %q: %s", label.Name, err))
+			return
+		}
+	}
+}
+
+// listUserLabels returns the user labels within prefix.
+func (r *LabelsResource) listUserLabels(prefix string) ([]*gmail.Label, error) {
+	res, err := r.config.gmailService.Users.Labels.List(gmailUser).Do()
+	if err != nil {
+		return nil, err
+	}
+	var labels []*gmail.Label
+	for _, label := range res.Labels {
+		if label.Type == "user" && labelUnderPrefix(label.Name, prefix) {
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+// labelUnderPrefix reports whether name is the label prefix itself or nested
+// under it. An empty prefix matches every name.
+func labelUnderPrefix(name, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+func expandLabelsEntry(entry LabelsEntryModel) *gmail.Label {
+	label := &gmail.Label{
+		Name:                  entry.Name.ValueString(),
+		LabelListVisibility:   entry.LabelListVisibility.ValueString(),
+		MessageListVisibility: entry.MessageListVisibility.ValueString(),
+	}
+	if bg, text, ok := labelsEntryColor(entry); ok {
+		label.Color = &gmail.LabelColor{
+			BackgroundColor: bg,
+			TextColor:       text,
+		}
+	}
+	return label
+}
+
+// labelsEntryColor resolves the configured colors of entry to hex strings.
+// ok is false unless both colors are set.
+func labelsEntryColor(entry LabelsEntryModel) (bg, text string, ok bool) {
+	if entry.BackgroundColor.IsNull() || entry.TextColor.IsNull() {
+		return "", "", false
+	}
+	bg, err := resolveLabelColor(entry.BackgroundColor.ValueString())
+	if err != nil {
+		return "", "", false
+	}
+	text, err = resolveLabelColor(entry.TextColor.ValueString())
+	if err != nil {
+		return "", "", false
+	}
+	return bg, text, true
+}
+
+// labelsEntryPatch returns the patch that brings current in line with entry,
+// or nil when nothing needs to change. Visibility left unset in configuration
+// is not managed, while unset colors clear the label color.
+func labelsEntryPatch(entry LabelsEntryModel, current *gmail.Label) *gmail.Label {
+	patch := &gmail.Label{}
+	changed := false
+
+	if entry.Name.ValueString() != current.Name {
+		patch.Name = entry.Name.ValueString()
+		changed = true
+	}
+	if v := entry.LabelListVisibility; !v.IsNull() && !v.IsUnknown() && v.ValueString() != current.LabelListVisibility {
+		patch.LabelListVisibility = v.ValueString()
+		changed = true
+	}
+	if v := entry.MessageListVisibility; !v.IsNull() && !v.IsUnknown() && v.ValueString() != current.MessageListVisibility {
+		patch.MessageListVisibility = v.ValueString()
+		changed = true
+	}
+
+	var currentBg, currentText string
+	if current.Color != nil {
+		currentBg, currentText = current.Color.BackgroundColor, current.Color.TextColor
+	}
+	if bg, text, ok := labelsEntryColor(entry); !ok {
+		if current.Color != nil {
+			patch.NullFields = append(patch.NullFields, "Color")
+			changed = true
+		}
+	} else if !labelColorsEqual(bg, currentBg) || !labelColorsEqual(text, currentText) {
+		patch.Color = &gmail.LabelColor{
+			BackgroundColor: bg,
+			TextColor:       text,
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return patch
+}
+
+// flattenLabelsEntry converts label into an entry. Colors from prior are kept
+// when they resolve to the same color, so palette names and differently cased
+// hex strings do not show up as a diff.
+func flattenLabelsEntry(label *gmail.Label, prior LabelsEntryModel) LabelsEntryModel {
+	entry := LabelsEntryModel{
+		ID:                    types.StringValue(label.Id),
+		Name:                  types.StringValue(label.Name),
+		BackgroundColor:       types.StringNull(),
+		TextColor:             types.StringNull(),
+		LabelListVisibility:   types.StringValue(label.LabelListVisibility),
+		MessageListVisibility: types.StringValue(label.MessageListVisibility),
+	}
+	if label.Color != nil {
+		entry.BackgroundColor = types.StringValue(label.Color.BackgroundColor)
+		entry.TextColor = types.StringValue(label.Color.TextColor)
+		if bg, text, ok := labelsEntryColor(prior); ok && labelColorsEqual(bg, label.Color.BackgroundColor) && labelColorsEqual(text, label.Color.TextColor) {
+			entry.BackgroundColor = prior.BackgroundColor
+			entry.TextColor = prior.TextColor
+		}
+	}
+	return entry
+}
@@ -0,0 +1,196 @@
+package gmailfilter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/gmail/v1"
+)
+
+var _ resource.Resource = &SystemLabelResource{}
+var _ resource.ResourceWithImportState = &SystemLabelResource{}
+
+func NewSystemLabelResource() resource.Resource {
+	return &SystemLabelResource{}
+}
+
+type SystemLabelResource struct {
+	config *Config
+}
+
+type SystemLabelResourceModel struct {
+	ID                    types.String `tfsdk:"id"`
+	Name                  types.String `tfsdk:"name"`
+	LabelListVisibility   types.String `tfsdk:"label_list_visibility"`
+	MessageListVisibility types.String `tfsdk:"message_list_visibility"`
+}
+
+func (r *SystemLabelResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_system_label"
+}
+
+func (r *SystemLabelResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages the visibility of a Gmail system label such as STARRED or CATEGORY_PROMOTIONS. The label itself is never created or deleted; destroying this resource leaves the label as it is.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Required:    true,
+				Description: "The ID of the system label, e.g. CATEGORY_SOCIAL. Changing this will require the resource to be recreated.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The display name of the label",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"label_list_visibility": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The visibility of the label in the label list in the Gmail web interface (labelShow/labelShowIfUnread/labelHide)",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+				Validators: []validator.String{
+					stringOneOf(labelListVisibilityValues...),
+				},
+			},
+			"message_list_visibility": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The visibility of messages with this label in the message list (show/hide)",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+				Validators: []validator.String{
+					stringOneOf(messageListVisibilityValues...),
+				},
+			},
+		},
+	}
+}
+
+func (r *SystemLabelResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Resource Configure Type", "Expected *Config")
+		return
+	}
+	r.config = config
+}
+
+func (r *SystemLabelResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data SystemLabelResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	r.apply(&data, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SystemLabelResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data SystemLabelResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	label, err := r.config.gmailService.Users.Labels.Get(gmailUser, data.ID.ValueString()).Do()
+	if err != nil {
+		if isNotFoundError(err) {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		resp.Diagnostics.AddError("Failed to read label", err.Error())
+		return
+	}
+
+	r.updateModelFromAPIResponse(&data, label)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SystemLabelResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data SystemLabelResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	r.apply(&data, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SystemLabelResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// System labels cannot be deleted, so the label is only removed from
+	// Terraform state and keeps its current visibility.
+}
+
+func (r *SystemLabelResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+// apply patches the visibility of the system label in data, leaving
+// attributes that are not configured untouched.
+func (r *SystemLabelResource) apply(data *SystemLabelResourceModel, diags *diag.Diagnostics) {
+	label, err := r.config.gmailService.Users.Labels.Get(gmailUser, data.ID.ValueString()).Do()
+	if err != nil {
+		diags.AddError("Failed to read label", err.Error())
+		return
+	}
+	if label.Type != "system" {
+		diags.AddAttributeError(path.Root("id"), "Not a system label",
+			fmt.Sprintf("Label %q (ID %s) is a %s label; use gmailfilter_label to manage it", label.Name, label.Id, label.Type))
+		return
+	}
+
+	patch := &gmail.Label{}
+	changed := false
+	if v := data.LabelListVisibility; !v.IsNull() && !v.IsUnknown() && v.ValueString() != label.LabelListVisibility {
+		patch.LabelListVisibility = v.ValueString()
+		changed = true
+	}
+	if v := data.MessageListVisibility; !v.IsNull() && !v.IsUnknown() && v.ValueString() != label.MessageListVisibility {
+		patch.MessageListVisibility = v.ValueString()
+		changed = true
+	}
+
+	if changed {
+		label, err = r.config.gmailService.Users.Labels.Patch(gmailUser, label.Id, patch).Do()
+		if err != nil {
+			diags.AddError("Failed to update label", err.Error())
+			return
+		}
+	}
+
+	r.updateModelFromAPIResponse(data, label)
+}
+
+func (r *SystemLabelResource) updateModelFromAPIResponse(data *SystemLabelResourceModel, label *gmail.Label) {
+	data.Name = types.StringValue(label.Name)
+	data.LabelListVisibility = types.StringValue(label.LabelListVisibility)
+	data.MessageListVisibility = types.StringValue(label.MessageListVisibility)
+}
@@ -0,0 +1,189 @@
+package gmailfilter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+var _ validator.String = labelColorValidator{}
+var _ validator.String = stringOneOfValidator{}
+var _ validator.Int64 = int64AtLeastValidator{}
+
+// labelColorValidator checks that a string is a color from the Gmail label
+// palette, either as hex string or by name.
+type labelColorValidator struct{}
+
+func (v labelColorValidator) Description(ctx context.Context) string {
+	return "value must be a Gmail label palette color, as hex string or palette name"
+}
+
+func (v labelColorValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v labelColorValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := resolveLabelColor(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid label color", err.Error())
+	}
+}
+
+// stringOneOfValidator checks that a string is one of a fixed set of values.
+type stringOneOfValidator struct {
+	values []string
+}
+
+func stringOneOf(values ...string) stringOneOfValidator {
+	return stringOneOfValidator{values: values}
+}
+
+func (v stringOneOfValidator) Description(ctx context.Context) string {
+	return fmt.Sprintf("value must be one of: %s", strings.Join(v.values, ", "))
+}
+
+func (v stringOneOfValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v stringOneOfValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	for _, value := range v.values {
+		if req.ConfigValue.ValueString() == value {
+			return
+		}
+	}
+	resp.Diagnostics.AddAttributeError(req.Path, "Invalid value",
+		fmt.Sprintf("%q is not valid, %s", req.ConfigValue.ValueString(), v.Description(ctx)))
+}
+
+// int64AtLeastValidator checks that a number is not below a minimum.
+type int64AtLeastValidator struct {
+	min int64
+}
+
+func int64AtLeast(min int64) int64AtLeastValidator {
+	return int64AtLeastValidator{min: min}
+}
+
+func (v int64AtLeastValidator) Description(ctx context.Context) string {
+	return fmt.Sprintf("value must be at least %d", v.min)
+}
+
+func (v int64AtLeastValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v int64AtLeastValidator) ValidateInt64(ctx context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if req.ConfigValue.ValueInt64() < v.min {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid value",
+			fmt.Sprintf("%d is not valid, %s", req.ConfigValue.ValueInt64(), v.Description(ctx)))
+	}
+}
+
+// Values Gmail accepts for label_list_visibility and message_list_visibility.
+var (
+	labelListVisibilityValues   = []string{"labelShow", "labelShowIfUnread", "labelHide"}
+	messageListVisibilityValues = []string{"show", "hide"}
+)
+
+// maxLabelNameLength is the longest label name Gmail accepts.
+const maxLabelNameLength = 225
+
+// reservedLabelNames are names Gmail uses for system labels, which cannot be
+// used for user labels regardless of case.
+var reservedLabelNames = []string{
+	"inbox", "spam", "trash", "unread", "starred", "important", "sent", "draft", "drafts",
+	"chat", "chats", "all mail", "snoozed", "scheduled", "outbox", "muted", "bin",
+}
+
+func isReservedLabelName(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if strings.HasPrefix(name, "category_") {
+		return true
+	}
+	for _, reserved := range reservedLabelNames {
+		if name == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+var _ validator.String = labelNameValidator{}
+
+// labelNameValidator checks that a string is a label name Gmail accepts: no
+// leading or trailing slash, no empty path segments and not too long. With
+// rejectReserved set, system label names are rejected as well.
+type labelNameValidator struct {
+	rejectReserved bool
+}
+
+func (v labelNameValidator) Description(ctx context.Context) string {
+	return fmt.Sprintf("value must be a label name of at most %d characters without leading, trailing or repeated slashes", maxLabelNameLength)
+}
+
+func (v labelNameValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v labelNameValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	name := req.ConfigValue.ValueString()
+
+	switch {
+	case strings.TrimSpace(name) == "":
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid label name", "Label name cannot be empty")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid label name",
+			fmt.Sprintf("Label name %q cannot start or end with a slash", name))
+	case strings.Contains(name, "//"):
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid label name",
+			fmt.Sprintf("Label name %q contains an empty path segment", name))
+	case len([]rune(name)) > maxLabelNameLength:
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid label name",
+			fmt.Sprintf("Label name is %d characters long, Gmail allows at most %d", len([]rune(name)), maxLabelNameLength))
+	case v.rejectReserved && isReservedLabelName(name):
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid label name",
+			fmt.Sprintf("%q is reserved for a Gmail system label", name))
+	}
+}
+
+var _ validator.String = maxAgeValidator{}
+
+// maxAgePattern matches the ages Gmail's older_than search operator accepts.
+var maxAgePattern = regexp.MustCompile(`^[1-9][0-9]*[dmy]$`)
+
+// maxAgeValidator checks that a string is an age for the older_than search
+// operator, such as 90d, 6m or 1y.
+type maxAgeValidator struct{}
+
+func (v maxAgeValidator) Description(ctx context.Context) string {
+	return "value must be a number followed by d (days), m (months) or y (years)"
+}
+
+func (v maxAgeValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v maxAgeValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if !maxAgePattern.MatchString(req.ConfigValue.ValueString()) {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid max age",
+			fmt.Sprintf("%q is not valid, %s", req.ConfigValue.ValueString(), v.Description(ctx)))
+	}
+}
@@ -0,0 +1,203 @@
+package simulation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+// Message is the part of an email that filters are evaluated against.
+type Message struct {
+	// ID identifies the message in results, e.g. the path it was read from.
+	ID string
+
+	// MessageID is the Message-ID header, without angle brackets.
+	MessageID string
+
+	From    string
+	To      string
+	Cc      string
+	Bcc     string
+	Subject string
+	ListID  string
+
+	// DeliveredTo holds the Delivered-To and X-Original-To headers.
+	DeliveredTo string
+
+	// Body is the decoded text of all text/plain and text/html parts.
+	Body string
+
+	// Size is the size of the entire RFC 822 message in bytes.
+	Size int64
+
+	// Attachments holds the file names of all attachments. An attachment
+	// without a file name is recorded as an empty string.
+	Attachments []string
+
+	// IsChat marks chat messages, which Gmail stores alongside email. Parsed
+	// files are never chats.
+	IsChat bool
+}
+
+// HasAttachment reports whether the message has at least one attachment.
+func (m *Message) HasAttachment() bool {
+	return len(m.Attachments) > 0
+}
+
+// ParseFile reads an .eml file. The ID of the message is the path.
+func ParseFile(path string) (*Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMessage(path, f)
+}
+
+// ParseMessage parses an RFC 822 message.
+func ParseMessage(id string, r io.Reader) (*Message, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", id, err)
+	}
+
+	m := &Message{
+		ID:          id,
+		MessageID:   strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>"),
+		From:        addressHeader(msg.Header, "From"),
+		To:          addressHeader(msg.Header, "To"),
+		Cc:          addressHeader(msg.Header, "Cc"),
+		Bcc:         addressHeader(msg.Header, "Bcc"),
+		Subject:     decodeHeader(msg.Header.Get("Subject")),
+		ListID:      decodeHeader(msg.Header.Get("List-Id")),
+		DeliveredTo: strings.TrimSpace(msg.Header.Get("Delivered-To") + " " + msg.Header.Get("X-Original-To")),
+		Size:        int64(len(raw)),
+	}
+
+	var body strings.Builder
+	if err := m.walkPart(msg.Header, msg.Body, &body); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", id, err)
+	}
+	m.Body = body.String()
+	return m, nil
+}
+
+// header is the subset of mail.Header and textproto.MIMEHeader used when
+// walking MIME parts.
+type header interface {
+	Get(key string) string
+}
+
+// walkPart records the attachments and text of a MIME part and its children.
+func (m *Message) walkPart(h header, r io.Reader, body *strings.Builder) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.walkPart(part.Header, part, body); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	filename := decodeHeader(dispParams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+	if disposition == "attachment" || filename != "" {
+		m.Attachments = append(m.Attachments, filename)
+		return nil
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return nil
+	}
+	text, err := io.ReadAll(decodeTransferEncoding(h.Get("Content-Transfer-Encoding"), r))
+	if err != nil {
+		return err
+	}
+	if mediaType == "text/html" {
+		text = stripTags(text)
+	}
+	body.Write(text)
+	body.WriteByte('\n')
+	return nil
+}
+
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// The decoder skips the line breaks between encoded lines.
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// stripTags replaces HTML tags with spaces, which is enough to match words.
+func stripTags(html []byte) []byte {
+	var out []byte
+	inTag := false
+	for _, b := range html {
+		switch {
+		case b == '<':
+			inTag = true
+			out = append(out, ' ')
+		case b == '>':
+			inTag = false
+		case !inTag:
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+var wordDecoder = &mime.WordDecoder{}
+
+func decodeHeader(v string) string {
+	if decoded, err := wordDecoder.DecodeHeader(v); err == nil {
+		return decoded
+	}
+	return v
+}
+
+// addressHeader returns an address header as "Name <address>" entries joined
+// by commas, or the decoded raw value if it cannot be parsed.
+func addressHeader(h mail.Header, key string) string {
+	addrs, err := h.AddressList(key)
+	if err != nil {
+		return decodeHeader(h.Get(key))
+	}
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		parts[i] = a.Address
+		if a.Name != "" {
+			parts[i] = a.Name + " <" + a.Address + ">"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
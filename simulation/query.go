@@ -0,0 +1,433 @@
+package simulation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed Gmail search query.
+//
+// The supported subset covers free text and quoted phrases, AND (implicit),
+// OR and |, negation with -, grouping with () and {}, and the operators
+// from:, to:, cc:, bcc:, subject:, list:, deliveredto:, filename:,
+// has:attachment, larger:, smaller:, size:, is:chat and in:chats. Operators
+// that depend on mailbox state, such as label: or is:unread, are rejected.
+//
+// Gmail documents larger: and size: as "larger than" and smaller: as "smaller
+// than" the given size, so a message of exactly that size matches neither.
+type Query struct {
+	root node
+}
+
+// UnsupportedError is returned by ParseQuery for queries that use operators
+// outside the supported subset.
+type UnsupportedError struct {
+	Operator string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("unsupported query operator %q", e.Operator)
+}
+
+// ParseQuery parses a Gmail search query. An empty query matches every
+// message.
+func ParseQuery(q string) (*Query, error) {
+	p := &parser{tokens: tokenize(q)}
+	if len(p.tokens) == 0 {
+		return &Query{root: andNode{}}, nil
+	}
+	root, err := p.parseOr("")
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q in query %q", p.peek().text, q)
+	}
+	return &Query{root: root}, nil
+}
+
+// Matches reports whether m matches the query.
+func (q *Query) Matches(m *Message) bool {
+	return q.root.matches(m)
+}
+
+type node interface {
+	matches(m *Message) bool
+}
+
+type andNode []node
+
+func (n andNode) matches(m *Message) bool {
+	for _, c := range n {
+		if !c.matches(m) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []node
+
+func (n orNode) matches(m *Message) bool {
+	for _, c := range n {
+		if c.matches(m) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct {
+	node
+}
+
+func (n notNode) matches(m *Message) bool {
+	return !n.node.matches(m)
+}
+
+type funcNode func(m *Message) bool
+
+func (n funcNode) matches(m *Message) bool {
+	return n(m)
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenOpen
+	tokenClose
+	tokenBraceOpen
+	tokenBraceClose
+	tokenMinus
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a query into words, quoted phrases, brackets and leading
+// minus signs. An operator and its value stay in one word, e.g. "from:bob";
+// "from:(a b)" yields the word "from:" followed by a bracketed group.
+func tokenize(q string) []token {
+	var tokens []token
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenOpen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenClose, ")"})
+			i++
+		case r == '{':
+			tokens = append(tokens, token{tokenBraceOpen, "{"})
+			i++
+		case r == '}':
+			tokens = append(tokens, token{tokenBraceClose, "}"})
+			i++
+		case r == '-' && (i == 0 || unicode.IsSpace(runes[i-1]) || strings.ContainsRune("({", runes[i-1])):
+			tokens = append(tokens, token{tokenMinus, "-"})
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			tokens = append(tokens, token{tokenPhrase, string(runes[i+1 : min(j, len(runes))])})
+			i = j + 1
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune(`(){}`, runes[j]) {
+				if runes[j] == '"' {
+					// A quoted value directly after an operator, e.g.
+					// subject:"hello world".
+					k := j + 1
+					for k < len(runes) && runes[k] != '"' {
+						k++
+					}
+					j = min(k+1, len(runes))
+					continue
+				}
+				j++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[i:j])})
+			i = j
+		}
+	}
+	return tokens
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *parser) isOr() bool {
+	if p.done() {
+		return false
+	}
+	t := p.peek()
+	return t.kind == tokenWord && (t.text == "OR" || t.text == "|")
+}
+
+// parseOr parses terms joined by OR. field is the operator that applies to
+// plain terms, for groups such as from:(a OR b), or empty.
+func (p *parser) parseOr(field string) (node, error) {
+	first, err := p.parseAnd(field)
+	if err != nil {
+		return nil, err
+	}
+	nodes := orNode{first}
+	for p.isOr() {
+		p.next()
+		n, err := p.parseAnd(field)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseAnd(field string) (node, error) {
+	var nodes andNode
+	for !p.done() && !p.isOr() {
+		if k := p.peek().kind; k == tokenClose || k == tokenBraceClose {
+			break
+		}
+		n, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("expected a search term")
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseUnary(field string) (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenMinus:
+		if p.done() {
+			return nil, fmt.Errorf("expected a search term after -")
+		}
+		n, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case tokenOpen:
+		n, err := p.parseOr(field)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(tokenClose)
+	case tokenBraceOpen:
+		var nodes orNode
+		for !p.done() && p.peek().kind != tokenBraceClose {
+			n, err := p.parseUnary(field)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+		}
+		return nodes, p.expect(tokenBraceClose)
+	case tokenPhrase:
+		return termNode(field, t.text)
+	case tokenWord:
+		op, value, ok := strings.Cut(t.text, ":")
+		if !ok || op == "" || strings.HasPrefix(op, `"`) {
+			return termNode(field, t.text)
+		}
+		op = strings.ToLower(op)
+		if !knownOperators[op] {
+			// Not an operator, e.g. a URL.
+			return termNode(field, t.text)
+		}
+		if value == "" {
+			// An operator applied to a group, e.g. subject:(a b).
+			if p.done() || p.peek().kind != tokenOpen && p.peek().kind != tokenBraceOpen {
+				return nil, fmt.Errorf("expected a value after %s:", op)
+			}
+			return p.parseUnary(op)
+		}
+		return operatorNode(op, strings.Trim(value, `"`))
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if p.done() || p.peek().kind != kind {
+		return fmt.Errorf("unbalanced brackets")
+	}
+	p.next()
+	return nil
+}
+
+// knownOperators holds the Gmail search operators, including those that
+// operatorNode does not support.
+var knownOperators = map[string]bool{
+	"from": true, "to": true, "cc": true, "bcc": true, "subject": true,
+	"list": true, "deliveredto": true, "filename": true, "has": true,
+	"is": true, "in": true, "larger": true, "smaller": true, "size": true,
+	"label": true, "category": true, "after": true, "before": true,
+	"older": true, "newer": true, "older_than": true, "newer_than": true,
+	"rfc822msgid": true, "around": true,
+}
+
+// termNode matches a plain term or phrase, within field if it is set.
+func termNode(field, text string) (node, error) {
+	if field == "" {
+		return textNode(text), nil
+	}
+	return operatorNode(field, text)
+}
+
+// textNode matches a word or phrase anywhere in the headers or body.
+func textNode(text string) node {
+	return funcNode(func(m *Message) bool {
+		for _, s := range []string{m.From, m.To, m.Cc, m.Subject, m.Body} {
+			if containsPhrase(s, text) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func operatorNode(op, value string) (node, error) {
+	fieldNode := func(get func(m *Message) string) node {
+		return funcNode(func(m *Message) bool {
+			return containsFold(get(m), value)
+		})
+	}
+
+	switch op {
+	case "from":
+		return fieldNode(func(m *Message) string { return m.From }), nil
+	case "to":
+		return fieldNode(func(m *Message) string { return m.To + ", " + m.Cc + ", " + m.Bcc }), nil
+	case "cc":
+		return fieldNode(func(m *Message) string { return m.Cc }), nil
+	case "bcc":
+		return fieldNode(func(m *Message) string { return m.Bcc }), nil
+	case "list":
+		return fieldNode(func(m *Message) string { return m.ListID }), nil
+	case "deliveredto":
+		return fieldNode(func(m *Message) string { return m.DeliveredTo }), nil
+	case "subject":
+		return funcNode(func(m *Message) bool { return containsPhrase(m.Subject, value) }), nil
+	case "filename":
+		return funcNode(func(m *Message) bool {
+			for _, name := range m.Attachments {
+				if containsFold(name, value) {
+					return true
+				}
+			}
+			return false
+		}), nil
+	case "has":
+		if strings.EqualFold(value, "attachment") {
+			return funcNode(func(m *Message) bool { return m.HasAttachment() }), nil
+		}
+	case "is":
+		if strings.EqualFold(value, "chat") {
+			return funcNode(func(m *Message) bool { return m.IsChat }), nil
+		}
+	case "in":
+		if strings.EqualFold(value, "chats") {
+			return funcNode(func(m *Message) bool { return m.IsChat }), nil
+		}
+	case "larger", "size", "smaller":
+		size, err := parseSize(value)
+		if err != nil {
+			return nil, err
+		}
+		if op == "smaller" {
+			return funcNode(func(m *Message) bool { return m.Size < size }), nil
+		}
+		return funcNode(func(m *Message) bool { return m.Size > size }), nil
+	}
+	return nil, &UnsupportedError{Operator: op + ":" + value}
+}
+
+// parseSize parses a size such as 2048, 10K or 5M into bytes.
+func parseSize(s string) (int64, error) {
+	u := strings.ToUpper(strings.TrimSuffix(strings.ToUpper(s), "B"))
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(u, "K"):
+		multiplier, u = 1024, strings.TrimSuffix(u, "K")
+	case strings.HasSuffix(u, "M"):
+		multiplier, u = 1024*1024, strings.TrimSuffix(u, "M")
+	}
+	n, err := strconv.ParseInt(u, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// containsPhrase reports whether the words of phrase appear consecutively in
+// s, ignoring case and punctuation.
+func containsPhrase(s, phrase string) bool {
+	want := words(phrase)
+	if len(want) == 0 {
+		return true
+	}
+	have := words(s)
+	for i := 0; i+len(want) <= len(have); i++ {
+		match := true
+		for j, w := range want {
+			if have[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,136 @@
+package simulation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []token
+	}{
+		{"", nil},
+		{"foo bar", []token{{tokenWord, "foo"}, {tokenWord, "bar"}}},
+		{`"foo bar" baz`, []token{{tokenPhrase, "foo bar"}, {tokenWord, "baz"}}},
+		{"-foo", []token{{tokenMinus, "-"}, {tokenWord, "foo"}}},
+		{"foo-bar", []token{{tokenWord, "foo-bar"}}},
+		{"(-foo)", []token{{tokenOpen, "("}, {tokenMinus, "-"}, {tokenWord, "foo"}, {tokenClose, ")"}}},
+		{"{a b}", []token{{tokenBraceOpen, "{"}, {tokenWord, "a"}, {tokenWord, "b"}, {tokenBraceClose, "}"}}},
+		{"a OR b", []token{{tokenWord, "a"}, {tokenWord, "OR"}, {tokenWord, "b"}}},
+		{"from:bob", []token{{tokenWord, "from:bob"}}},
+		{"from:(a b)", []token{{tokenWord, "from:"}, {tokenOpen, "("}, {tokenWord, "a"}, {tokenWord, "b"}, {tokenClose, ")"}}},
+		{`subject:"hello world" x`, []token{{tokenWord, `subject:"hello world"`}, {tokenWord, "x"}}},
+		{`"unterminated`, []token{{tokenPhrase, "unterminated"}}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query       string
+		unsupported bool
+	}{
+		{"(foo", false},
+		{"foo)", false},
+		{"{foo", false},
+		{"foo}", false},
+		{"foo -", false},
+		{"foo OR", false},
+		{"from:", false},
+		{"larger:lots", false},
+		{"label:work", true},
+		{"is:unread", true},
+		{"older_than:1y", true},
+		{"from:(a OR label:b)", true},
+	}
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		if err == nil {
+			t.Errorf("ParseQuery(%q) succeeded, want an error", tt.query)
+			continue
+		}
+		var unsupported *UnsupportedError
+		if got := errors.As(err, &unsupported); got != tt.unsupported {
+			t.Errorf("ParseQuery(%q) error %q, unsupported = %v, want %v", tt.query, err, got, tt.unsupported)
+		}
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	m := &Message{
+		From:        "Alice <alice@example.com>",
+		To:          "bob@example.org",
+		Cc:          "carol@example.net",
+		Subject:     "Weekly report: sales",
+		ListID:      "news.example.com",
+		DeliveredTo: "me@example.com",
+		Body:        "Please find the invoice attached. See https://example.com/x",
+		Size:        2048,
+		Attachments: []string{"invoice.pdf"},
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"invoice", true},
+		{"INVOICE", true},
+		{"receipt", false},
+		{"invoice attached", true},
+		{"invoice receipt", false},
+		{`"invoice attached"`, true},
+		{`"attached invoice"`, false},
+		{"invoice OR receipt", true},
+		{"receipt | invoice", true},
+		{"receipt OR refund", false},
+		{"-receipt", true},
+		{"-invoice", false},
+		{"{receipt invoice}", true},
+		{"{receipt refund}", false},
+		{"(receipt OR invoice) weekly", true},
+		{"https://example.com/x", true},
+		{"from:alice", true},
+		{"from:bob", false},
+		{"from:(bob OR alice)", true},
+		{"from:{bob alice}", true},
+		{"from:(alice example.com)", true},
+		{"from:(alice bob)", false},
+		{"-from:alice", false},
+		{"to:carol", true},
+		{"cc:carol", true},
+		{"cc:bob", false},
+		{"bcc:bob", false},
+		{"subject:weekly", true},
+		{`subject:"weekly report"`, true},
+		{`subject:"report weekly"`, false},
+		{"subject:(sales weekly)", true},
+		{"list:news.example.com", true},
+		{"deliveredto:me@example.com", true},
+		{"filename:pdf", true},
+		{"filename:doc", false},
+		{"has:attachment", true},
+		{"larger:1K", true},
+		{"larger:2048", false},
+		{"size:2047", true},
+		{"smaller:2048", false},
+		{"smaller:3K", true},
+		{"is:chat", false},
+		{"-in:chats", true},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if got := q.Matches(m); got != tt.want {
+			t.Errorf("ParseQuery(%q).Matches() = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package simulation evaluates Gmail filters against messages offline, so the
+// effect of filter changes can be checked before they are rolled out.
+//
+// Matching follows Gmail where it can be determined from the message alone:
+// from, to and list match substrings of the address headers, subject and free
+// text match whole words, and size is compared against the size of the raw
+// message. The from, to and subject criteria of a filter are search
+// expressions like its query, scoped to their header, so "a OR b" matches
+// either. Gmail's own matching is not documented in full, so results are an
+// approximation.
+package simulation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Criteria is a compiled gmail.FilterCriteria.
+type Criteria struct {
+	criteria     gmail.FilterCriteria
+	fields       []*Query
+	query        *Query
+	negatedQuery *Query
+}
+
+// CompileCriteria parses the from, to and subject criteria and the queries
+// of c. It returns an *UnsupportedError if they use operators outside the
+// subset supported by ParseQuery.
+func CompileCriteria(c *gmail.FilterCriteria) (*Criteria, error) {
+	compiled := &Criteria{}
+	if c != nil {
+		compiled.criteria = *c
+	}
+	for _, field := range []struct{ op, value string }{
+		{"from", compiled.criteria.From},
+		{"to", compiled.criteria.To},
+		{"subject", compiled.criteria.Subject},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			continue
+		}
+		q, err := ParseQuery(field.op + ":(" + field.value + ")")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.op, err)
+		}
+		compiled.fields = append(compiled.fields, q)
+	}
+	var err error
+	if compiled.query, err = ParseQuery(compiled.criteria.Query); err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	if compiled.criteria.NegatedQuery != "" {
+		if compiled.negatedQuery, err = ParseQuery(compiled.criteria.NegatedQuery); err != nil {
+			return nil, fmt.Errorf("negated query: %w", err)
+		}
+	}
+	return compiled, nil
+}
+
+// Matches reports whether m matches every criterion. Like the larger: and
+// smaller: operators, size criteria compare strictly.
+func (c *Criteria) Matches(m *Message) bool {
+	cr := c.criteria
+	for _, q := range c.fields {
+		if !q.Matches(m) {
+			return false
+		}
+	}
+	if cr.HasAttachment && !m.HasAttachment() {
+		return false
+	}
+	if cr.ExcludeChats && m.IsChat {
+		return false
+	}
+	if cr.Size != 0 {
+		switch cr.SizeComparison {
+		case "larger":
+			if m.Size <= cr.Size {
+				return false
+			}
+		case "smaller":
+			if m.Size >= cr.Size {
+				return false
+			}
+		}
+	}
+	if !c.query.Matches(m) {
+		return false
+	}
+	if c.negatedQuery != nil && c.negatedQuery.Matches(m) {
+		return false
+	}
+	return true
+}
+
+// Filter is a compiled gmail.Filter.
+type Filter struct {
+	ID       string
+	Action   gmail.FilterAction
+	criteria *Criteria
+}
+
+// CompileFilter compiles the criteria of f.
+func CompileFilter(f *gmail.Filter) (*Filter, error) {
+	criteria, err := CompileCriteria(f.Criteria)
+	if err != nil {
+		return nil, fmt.Errorf("filter %s: %w", f.Id, err)
+	}
+	compiled := &Filter{ID: f.Id, criteria: criteria}
+	if f.Action != nil {
+		compiled.Action = *f.Action
+	}
+	return compiled, nil
+}
+
+// Matches reports whether the filter applies to m.
+func (f *Filter) Matches(m *Message) bool {
+	return f.criteria.Matches(m)
+}
+
+// Result is the combined effect of all filters on a message.
+type Result struct {
+	Message *Message
+
+	// FilterIDs are the IDs of the matching filters.
+	FilterIDs []string
+
+	// AddLabelIDs and RemoveLabelIDs are the labels that the matching
+	// filters add and remove. A label can be in both when filters conflict.
+	AddLabelIDs    []string
+	RemoveLabelIDs []string
+
+	// Forwards are the addresses the message is forwarded to.
+	Forwards []string
+}
+
+// Simulate applies every filter to m. All lists in the result are sorted.
+func Simulate(filters []*Filter, m *Message) Result {
+	result := Result{
+		Message:        m,
+		FilterIDs:      []string{},
+		AddLabelIDs:    []string{},
+		RemoveLabelIDs: []string{},
+		Forwards:       []string{},
+	}
+	add, remove, forwards := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, f := range filters {
+		if !f.Matches(m) {
+			continue
+		}
+		result.FilterIDs = append(result.FilterIDs, f.ID)
+		for _, id := range f.Action.AddLabelIds {
+			add[id] = true
+		}
+		for _, id := range f.Action.RemoveLabelIds {
+			remove[id] = true
+		}
+		if f.Action.Forward != "" {
+			forwards[f.Action.Forward] = true
+		}
+	}
+
+	sort.Strings(result.FilterIDs)
+	result.AddLabelIDs = appendSorted(result.AddLabelIDs, add)
+	result.RemoveLabelIDs = appendSorted(result.RemoveLabelIDs, remove)
+	result.Forwards = appendSorted(result.Forwards, forwards)
+	return result
+}
+
+func appendSorted(s []string, set map[string]bool) []string {
+	for v := range set {
+		s = append(s, v)
+	}
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,120 @@
+package simulation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestCriteriaMatches(t *testing.T) {
+	m := &Message{
+		From:        "a@x.com",
+		To:          "me@example.com",
+		Subject:     "Your weekly report",
+		Body:        "Numbers are up",
+		Size:        1000,
+		Attachments: []string{"report.csv"},
+	}
+	tests := []struct {
+		name     string
+		criteria *gmail.FilterCriteria
+		want     bool
+	}{
+		{"nil", nil, true},
+		{"from", &gmail.FilterCriteria{From: "a@x.com"}, true},
+		{"from domain", &gmail.FilterCriteria{From: "x.com"}, true},
+		{"from other", &gmail.FilterCriteria{From: "b@y.com"}, false},
+		{"from with OR", &gmail.FilterCriteria{From: "a@x.com OR b@y.com"}, true},
+		{"from with OR, other", &gmail.FilterCriteria{From: "c@z.com OR b@y.com"}, false},
+		{"from with braces", &gmail.FilterCriteria{From: "{b@y.com a@x.com}"}, true},
+		{"from with negation", &gmail.FilterCriteria{From: "x.com -a@x.com"}, false},
+		{"to", &gmail.FilterCriteria{To: "me@example.com"}, true},
+		{"subject words", &gmail.FilterCriteria{Subject: "report weekly"}, true},
+		{"subject phrase", &gmail.FilterCriteria{Subject: `"report weekly"`}, false},
+		{"subject with OR", &gmail.FilterCriteria{Subject: "invoice OR report"}, true},
+		{"query", &gmail.FilterCriteria{Query: "numbers"}, true},
+		{"negated query", &gmail.FilterCriteria{NegatedQuery: "numbers"}, false},
+		{"attachment", &gmail.FilterCriteria{HasAttachment: true}, true},
+		{"larger", &gmail.FilterCriteria{Size: 999, SizeComparison: "larger"}, true},
+		{"larger, equal", &gmail.FilterCriteria{Size: 1000, SizeComparison: "larger"}, false},
+		{"smaller, equal", &gmail.FilterCriteria{Size: 1000, SizeComparison: "smaller"}, false},
+		{"smaller", &gmail.FilterCriteria{Size: 1001, SizeComparison: "smaller"}, true},
+		{"all", &gmail.FilterCriteria{From: "x.com", Subject: "weekly", Query: "up", HasAttachment: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := CompileCriteria(tt.criteria)
+			if err != nil {
+				t.Fatalf("CompileCriteria: %v", err)
+			}
+			if got := c.Matches(m); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileCriteriaErrors(t *testing.T) {
+	for _, c := range []*gmail.FilterCriteria{
+		{From: "(a@x.com"},
+		{Subject: "a)"},
+		{Query: "label:work"},
+		{NegatedQuery: "is:unread"},
+	} {
+		if _, err := CompileCriteria(c); err == nil {
+			t.Errorf("CompileCriteria(%+v) succeeded, want an error", c)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	m, err := ParseMessage("test.eml", strings.NewReader("From: a@x.com\r\nTo: me@example.com\r\nSubject: Hello\r\n\r\nBody\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filters []*Filter
+	for _, f := range []*gmail.Filter{
+		{Id: "f1", Criteria: &gmail.FilterCriteria{From: "x.com"}, Action: &gmail.FilterAction{AddLabelIds: []string{"L2", "L1"}, RemoveLabelIds: []string{"INBOX"}}},
+		{Id: "f2", Criteria: &gmail.FilterCriteria{Subject: "hello"}, Action: &gmail.FilterAction{AddLabelIds: []string{"L1"}, Forward: "fwd@example.com"}},
+		{Id: "f3", Criteria: &gmail.FilterCriteria{From: "y.com"}, Action: &gmail.FilterAction{AddLabelIds: []string{"L3"}}},
+	} {
+		c, err := CompileFilter(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filters = append(filters, c)
+	}
+
+	got := Simulate(filters, m)
+	want := Result{
+		Message:        m,
+		FilterIDs:      []string{"f1", "f2"},
+		AddLabelIDs:    []string{"L1", "L2"},
+		RemoveLabelIDs: []string{"INBOX"},
+		Forwards:       []string{"fwd@example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Simulate() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseMessageBase64(t *testing.T) {
+	raw := "From: a@x.com\r\n" +
+		"Subject: Report\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"TnVtYmVycyBhcmUgdXAg\r\n" +
+		"dGhpcyB3ZWVrLCBzZWUg\r\n" +
+		"dGhlIGF0dGFjaGVkIHJl\r\n" +
+		"cG9ydA==\r\n"
+	m, err := ParseMessage("report.eml", strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if want := "Numbers are up this week, see the attached report"; strings.TrimSpace(m.Body) != want {
+		t.Errorf("Body = %q, want %q", m.Body, want)
+	}
+}